@@ -3,8 +3,9 @@
 package main
 
 import (
-	"net"
 	"os"
+
+	"github.com/zyxar/berry/sys"
 )
 
 var (
@@ -18,15 +19,9 @@ func initService() {
 		hostname = "RaspberryPi"
 	}
 	hostname += ":"
-	if conn, err := net.Dial("udp", "google.com:80"); err != nil {
+	if ip, err := sys.PrimaryAddr(); err != nil {
 		addr = "127.0.0.1"
 	} else {
-		addr = conn.LocalAddr().String()
-		for i := 0; i < len(addr); i++ {
-			if addr[i] == ':' {
-				addr = addr[:i]
-				break
-			}
-		}
+		addr = ip.String()
 	}
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/zyxar/berry/bus"
+	"github.com/zyxar/berry/device/hat"
+)
+
+var (
+	dev            = flag.Uint("bus", hat.EEPROM_BUS, "specify i2c bus")
+	addr           = flag.Uint("addr", hat.EEPROM_ADDR, "specify eeprom address")
+	commands       map[string]func(...string) error
+	errUnsupported = errors.New("unsupported command")
+	errNoFile      = errors.New("no image file specified")
+)
+
+func init() {
+	commands = map[string]func(...string) error{
+		"dump":  dump,
+		"read":  read,
+		"flash": flash,
+	}
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Println("usage: hateeprom [options] {COMMAND}")
+		flag.PrintDefaults()
+		fmt.Println("\navailable commands:")
+		fmt.Println("\t\"dump\" [FILE]\tprint the atoms of the eeprom, or of an image file")
+		fmt.Println("\t\"read\" FILE\tsave the eeprom image to FILE")
+		fmt.Println("\t\"flash\" FILE\tprogram the image in FILE, e.g. made by eepmake")
+		fmt.Println()
+		os.Exit(1)
+	}
+	args := flag.Args()
+	if fn, ok := commands[args[0]]; ok && fn != nil {
+		if err := fn(args[1:]...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, errUnsupported)
+	}
+}
+
+func open() (*bus.I2C, error) {
+	return bus.NewI2C(*addr, *dev)
+}
+
+func dump(args ...string) (err error) {
+	var image []byte
+	if len(args) > 0 {
+		image, err = ioutil.ReadFile(args[0])
+	} else {
+		var i *bus.I2C
+		if i, err = open(); err != nil {
+			return
+		}
+		defer i.Close()
+		image, err = hat.ReadImage(i)
+	}
+	if err != nil {
+		return
+	}
+	e, err := hat.Decode(image)
+	if err != nil {
+		return
+	}
+	fmt.Printf("eeprom: %d bytes\n", len(image))
+	if v := e.Vendor; v != nil {
+		fmt.Printf("vendor: %q\nproduct: %q\nuuid: %v\nproduct id: 0x%04x\nproduct version: 0x%04x\n",
+			v.Vendor, v.Product, v.UUID, v.ProductID, v.ProductVer)
+	}
+	if m := e.GPIO; m != nil {
+		fmt.Printf("drive: %d, slew: %d, hysteresis: %d, back power: %d\n", m.Drive, m.Slew, m.Hysteresis, m.BackPower)
+		for i, g := range m.Pins {
+			if g.Used {
+				fmt.Printf("\tGPIO%-2d function: %d, pull: %d\n", i, g.Function, g.Pull)
+			}
+		}
+	}
+	if e.DTBlob != nil {
+		if isText(e.DTBlob) {
+			fmt.Printf("dt overlay: %q\n", e.DTBlob)
+		} else {
+			fmt.Printf("dt blob: %d bytes\n", len(e.DTBlob))
+		}
+	}
+	for i, c := range e.Custom {
+		fmt.Printf("custom[%d]: %q\n", i, c)
+	}
+	for _, a := range e.Unknown {
+		fmt.Printf("atom 0x%04x: %X\n", a.Type, a.Data)
+	}
+	return
+}
+
+func isText(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+func read(args ...string) (err error) {
+	if len(args) == 0 {
+		return errNoFile
+	}
+	i, err := open()
+	if err != nil {
+		return
+	}
+	defer i.Close()
+	image, err := hat.ReadImage(i)
+	if err != nil {
+		return
+	}
+	return ioutil.WriteFile(args[0], image, 0644)
+}
+
+func flash(args ...string) (err error) {
+	if len(args) == 0 {
+		return errNoFile
+	}
+	image, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return
+	}
+	if _, err = hat.Decode(image); err != nil {
+		return
+	}
+	i, err := open()
+	if err != nil {
+		return
+	}
+	defer i.Close()
+	if err = hat.WriteImage(i, image); err != nil {
+		return
+	}
+	fmt.Printf("%d bytes written to i2c-%d 0x%02x\n", len(image), *dev, *addr)
+	return
+}
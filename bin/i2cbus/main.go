@@ -12,11 +12,13 @@ import (
 
 var (
 	dev            = flag.Uint("bus", 1, "specify i2c bus")
+	pec            = flag.Bool("pec", false, "enable packet error checking")
 	commands       map[string]func(...string) error
 	fns            []fn
 	errUnsupported = errors.New("unsupported command")
 	errNoRegister  = errors.New("no register specified")
 	errNoAddr      = errors.New("no i2c-bus address speified")
+	errNoData      = errors.New("no data specified")
 )
 
 type fn struct {
@@ -26,11 +28,18 @@ type fn struct {
 
 func init() {
 	commands = map[string]func(...string) error{
-		"scan":      scan,
-		"probe":     factory("probe"),
-		"readbyte":  factory("readbyte"),
-		"readword":  factory("readword"),
-		"readblock": factory("readblock"),
+		"scan":          scan,
+		"list":          list,
+		"probe":         factory("probe"),
+		"readbyte":      factory("readbyte"),
+		"readword":      factory("readword"),
+		"readblock":     factory("readblock"),
+		"readi2cblock":  factory("readi2cblock"),
+		"writebyte":     factory("writebyte"),
+		"writeword":     factory("writeword"),
+		"writeblock":    factory("writeblock"),
+		"writei2cblock": factory("writei2cblock"),
+		"quick":         factory("quick"),
 	}
 
 	fns = []fn{
@@ -102,6 +111,74 @@ func smbread(fd uintptr, size int, args []string) (err error) {
 	return nil
 }
 
+func parseBytes(args []string) (b []byte, err error) {
+	b = make([]byte, len(args))
+	var v uint64
+	for i, arg := range args {
+		if v, err = strconv.ParseUint(arg, 0, 8); err != nil {
+			return
+		}
+		b[i] = byte(v)
+	}
+	return
+}
+
+func smbwrite(fd uintptr, cmd string, args []string) (err error) {
+	if len(args) < 1 {
+		err = errNoRegister
+		return
+	}
+	reg, err := strconv.ParseUint(args[0], 10, 8)
+	if err != nil {
+		return
+	}
+	b, err := parseBytes(args[1:])
+	if err != nil {
+		return
+	}
+	var size int
+	switch cmd {
+	case "writebyte":
+		if len(b) != 1 {
+			return errNoData
+		}
+		size = bus.SMBUS_BYTE_DATA
+	case "writeword":
+		if len(b) != 2 {
+			return errNoData
+		}
+		size = bus.SMBUS_WORD_DATA
+	case "writeblock":
+		size = bus.SMBUS_BLOCK_DATA
+	case "writei2cblock":
+		size = bus.SMBUS_I2C_BLOCK_DATA
+	}
+	return bus.SMBusWriteSize(fd, uint8(reg), size, b...)
+}
+
+func readi2cblock(fd uintptr, args []string) (err error) {
+	if len(args) < 1 {
+		err = errNoRegister
+		return
+	}
+	reg, err := strconv.ParseUint(args[0], 10, 8)
+	if err != nil {
+		return
+	}
+	length := uint64(bus.SMBUS_I2C_BLOCK_MAX)
+	if len(args) > 1 {
+		if length, err = strconv.ParseUint(args[1], 0, 8); err != nil {
+			return
+		}
+	}
+	b, err := bus.SMBusReadI2CBlock(fd, uint8(reg), int(length))
+	if err != nil {
+		return
+	}
+	fmt.Printf("[%02d] %X\n", reg, b)
+	return
+}
+
 func factory(args ...string) func(args ...string) error {
 	var cmd string = args[0]
 	return func(args ...string) error {
@@ -117,6 +194,11 @@ func factory(args ...string) func(args ...string) error {
 			return err
 		}
 		defer s.Close()
+		if *pec {
+			if err = s.SetPEC(true); err != nil {
+				return err
+			}
+		}
 		mask := s.Mask()
 		fmt.Printf("bus: %d, addr: 0x%02x, mask: 0x%08X\n", *dev, addr, mask)
 		switch cmd {
@@ -126,6 +208,15 @@ func factory(args ...string) func(args ...string) error {
 			return smbread(s.Fd(), bus.SMBUS_WORD_DATA, args[1:])
 		case "readblock":
 			return smbread(s.Fd(), bus.SMBUS_BLOCK_DATA, args[1:])
+		case "readi2cblock":
+			return readi2cblock(s.Fd(), args[1:])
+		case "writebyte", "writeword", "writeblock", "writei2cblock":
+			return smbwrite(s.Fd(), cmd, args[1:])
+		case "quick":
+			if err = bus.SMBusWriteQuick(s.Fd(), bus.SMBUS_WRITE); err != nil {
+				return err
+			}
+			return bus.SMBusReadQuick(s.Fd())
 		case "probe":
 			for i := range fns {
 				if uint64(fns[i].code)&mask != 0 {
@@ -141,6 +232,31 @@ func factory(args ...string) func(args ...string) error {
 	}
 }
 
+func list(...string) error {
+	adapters, err := bus.I2CAdapters()
+	if err != nil {
+		return err
+	}
+	for _, a := range adapters {
+		fmt.Printf("i2c-%d\t%-10s\t%-32s\t0x%08X\n", a.Bus, a.Type, a.Name, a.Mask)
+		for _, c := range a.Clients {
+			driver := c.Driver
+			if driver == "" {
+				driver = "-"
+			}
+			var flags string
+			if c.TenBit {
+				flags += " ten-bit"
+			}
+			if c.Slave {
+				flags += " slave"
+			}
+			fmt.Printf("\t0x%02x\t%-16s\t%s%s\n", c.Addr, c.Name, driver, flags)
+		}
+	}
+	return nil
+}
+
 func scan(...string) error {
 	var addr uint
 	fmt.Println("     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f")
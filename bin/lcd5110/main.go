@@ -5,7 +5,6 @@ package main
 import (
 	"flag"
 	"fmt"
-	"net"
 	"os"
 	"os/signal"
 	"syscall"
@@ -30,16 +29,10 @@ func init() {
 	}
 	hostname += ":"
 	fmt.Println("pcd8544+nokia5110 service")
-	if conn, err := net.Dial("udp", "google.com:80"); err != nil {
+	if ip, err := sys.PrimaryAddr(); err != nil {
 		addr = "127.0.0.1"
 	} else {
-		addr = conn.LocalAddr().String()
-		for i := 0; i < len(addr); i++ {
-			if addr[i] == ':' {
-				addr = addr[:i]
-				break
-			}
-		}
+		addr = ip.String()
 	}
 	fmt.Println(hostname, addr)
 }
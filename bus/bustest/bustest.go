@@ -0,0 +1,194 @@
+// Package bustest provides scripted and recording bus transports, so that
+// drivers can be tested without the hardware.
+package bustest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Kinds of operations.
+const (
+	WRITE = "write" // i2c write
+	READ  = "read"  // i2c read
+	TX    = "tx"    // i2c write followed by read
+	XFER  = "xfer"  // full duplex spi transfer
+)
+
+// Op is a single bus operation: the bytes the driver is expected to send (W),
+// the bytes the device answers (R), and the error returned to the driver.
+type Op struct {
+	Kind string
+	W, R []byte
+	Err  error
+}
+
+func (this Op) String() string {
+	s := fmt.Sprintf("%s %s %s", this.Kind, hexOrDash(this.W), hexOrDash(this.R))
+	if this.Err != nil {
+		s += " " + this.Err.Error()
+	}
+	return s
+}
+
+func hexOrDash(b []byte) string {
+	if len(b) == 0 {
+		return "-"
+	}
+	return hex.EncodeToString(b)
+}
+
+func dashOrHex(s string) ([]byte, error) {
+	if s == "-" {
+		return nil, nil
+	}
+	return hex.DecodeString(s)
+}
+
+// Encode writes ops one per line as "KIND W R [ERROR]", with bytes in hex
+// and "-" for none.
+func Encode(w io.Writer, ops []Op) error {
+	for _, op := range ops {
+		if _, err := fmt.Fprintln(w, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode reads ops written by Encode. Blank lines and lines starting with
+// '#' are ignored.
+func Decode(r io.Reader) (ops []Op, err error) {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 4)
+		if len(fields) < 3 {
+			err = fmt.Errorf("line %d: malformed op: %q", line, text)
+			return
+		}
+		op := Op{Kind: fields[0]}
+		switch op.Kind {
+		case WRITE, READ, TX, XFER:
+		default:
+			err = fmt.Errorf("line %d: unknown op: %q", line, op.Kind)
+			return
+		}
+		if op.W, err = dashOrHex(fields[1]); err != nil {
+			err = fmt.Errorf("line %d: %v", line, err)
+			return
+		}
+		if op.R, err = dashOrHex(fields[2]); err != nil {
+			err = fmt.Errorf("line %d: %v", line, err)
+			return
+		}
+		if len(fields) == 4 {
+			op.Err = errors.New(fields[3])
+		}
+		ops = append(ops, op)
+	}
+	err = scanner.Err()
+	return
+}
+
+// Load reads ops from a file written by Recorder.Save.
+func Load(path string) ([]Op, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// script hands out expected ops in order and remembers every mismatch.
+type script struct {
+	m    sync.Mutex
+	ops  []Op
+	next int
+	errs []error
+}
+
+func (this *script) expect(kind string, w []byte, rlen int) (op Op, err error) {
+	defer this.m.Unlock()
+	this.m.Lock()
+	if this.next >= len(this.ops) {
+		err = fmt.Errorf("unexpected %s %s: script exhausted", kind, hexOrDash(w))
+	} else {
+		op = this.ops[this.next]
+		this.next++
+		switch {
+		case op.Kind != kind:
+			err = fmt.Errorf("op %d: got %s, expected %s", this.next, kind, op.Kind)
+		case !bytes.Equal(op.W, w):
+			err = fmt.Errorf("op %d: %s wrote %s, expected %s", this.next, kind, hexOrDash(w), hexOrDash(op.W))
+		case rlen != len(op.R) && (kind == READ || kind == TX):
+			err = fmt.Errorf("op %d: %s read %d bytes, expected %d", this.next, kind, rlen, len(op.R))
+		}
+	}
+	if err != nil {
+		this.errs = append(this.errs, err)
+	}
+	return
+}
+
+// Verify reports the first mismatch, or an error if not every expected op
+// was performed.
+func (this *script) Verify() error {
+	defer this.m.Unlock()
+	this.m.Lock()
+	if len(this.errs) > 0 {
+		return this.errs[0]
+	}
+	if this.next < len(this.ops) {
+		return fmt.Errorf("%d of %d ops not performed, next: %v", len(this.ops)-this.next, len(this.ops), this.ops[this.next])
+	}
+	return nil
+}
+
+// Recorder collects the ops performed through a recording transport.
+type Recorder struct {
+	m   sync.Mutex
+	ops []Op
+}
+
+func (this *Recorder) record(kind string, w, r []byte, err error) {
+	op := Op{Kind: kind, Err: err}
+	op.W = append(op.W, w...)
+	op.R = append(op.R, r...)
+	this.m.Lock()
+	this.ops = append(this.ops, op)
+	this.m.Unlock()
+}
+
+// Ops returns the ops recorded so far.
+func (this *Recorder) Ops() []Op {
+	defer this.m.Unlock()
+	this.m.Lock()
+	return append([]Op(nil), this.ops...)
+}
+
+// Save writes the recorded ops to path, for later replay.
+func (this *Recorder) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = Encode(f, this.Ops()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,129 @@
+package bustest
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	ops := []Op{
+		{Kind: WRITE, W: []byte{0x00, 0x01}},
+		{Kind: READ, R: []byte{0xff}},
+		{Kind: TX, W: []byte{0x00}, R: []byte{0x12, 0x34}},
+		{Kind: XFER, W: []byte{0x80, 0x00}, R: []byte{0x00, 0x42}, Err: errors.New("remote i/o error")},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, ops); err != nil {
+		t.Fatal(err)
+	}
+	expected := "write 0001 -\nread - ff\ntx 00 1234\nxfer 8000 0042 remote i/o error\n"
+	if buf.String() != expected {
+		t.Errorf("encoding mismatch: %q", buf.String())
+	}
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(ops) {
+		t.Fatalf("op number mismatch: %d", len(decoded))
+	}
+	for i := range ops {
+		if decoded[i].String() != ops[i].String() {
+			t.Errorf("op mismatch: %v != %v", decoded[i], ops[i])
+		}
+	}
+	if _, err = Decode(bytes.NewBufferString("poke 00 -\n")); err == nil {
+		t.Error("unknown op accepted")
+	}
+}
+
+func TestI2CScript(t *testing.T) {
+	m := NewI2C(
+		Op{Kind: WRITE, W: []byte{0x10, 0x20}},
+		Op{Kind: TX, W: []byte{0x10}, R: []byte{0x20}},
+	)
+	if err := m.Write(0x10, 0x20); err != nil {
+		t.Error(err)
+	}
+	r := make([]byte, 1)
+	if err := m.Tx([]byte{0x10}, r); err != nil {
+		t.Error(err)
+	}
+	if r[0] != 0x20 {
+		t.Errorf("read mismatch: %X", r)
+	}
+	if err := m.Verify(); err != nil {
+		t.Error(err)
+	}
+	if err := m.Write(0x00); err == nil {
+		t.Error("write past end of script accepted")
+	}
+	if err := m.Verify(); err == nil {
+		t.Error("mismatch not reported")
+	}
+
+	m = NewI2C(Op{Kind: WRITE, W: []byte{0x01}})
+	if err := m.Write(0x02); err == nil {
+		t.Error("unexpected data accepted")
+	}
+	m = NewI2C(Op{Kind: READ, R: []byte{0x01}})
+	if err := m.Verify(); err == nil {
+		t.Error("pending op not reported")
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	dev := NewSPI(
+		Op{Kind: XFER, W: []byte{0x80, 0x00}, R: []byte{0x00, 0x91}},
+		Op{Kind: XFER, W: []byte{0x02, 0x0f}, R: []byte{0x00, 0x00}},
+		Op{Kind: XFER, W: []byte{0x8a, 0x00}, R: []byte{0x00, 0x5a}},
+	)
+	rec := RecordSPI(dev)
+	p := []byte{0x80, 0x00}
+	if _, err := rec.WriteAndRead(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.Write([]byte{0x02, 0x0f}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.Read([]byte{0x8a, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if ops := rec.Ops(); len(ops) != 3 || !bytes.Equal(ops[2].W, []byte{0x8a, 0x00}) || !bytes.Equal(ops[2].R, []byte{0x00, 0x5a}) {
+		t.Fatalf("recorded ops mismatch: %v", ops)
+	}
+	path := filepath.Join(t.TempDir(), "session.txt")
+	if err := rec.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	ops, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := NewSPI(ops...)
+	p = []byte{0x80, 0x00}
+	if _, err = replay.WriteAndRead(p); err != nil {
+		t.Fatal(err)
+	}
+	if p[1] != 0x91 {
+		t.Errorf("replay mismatch: %X", p)
+	}
+	if _, err = replay.Write([]byte{0x02, 0x0f}); err != nil {
+		t.Fatal(err)
+	}
+	p = []byte{0x8a, 0x00}
+	if _, err = replay.Read(p); err != nil {
+		t.Fatal(err)
+	}
+	if p[1] != 0x5a {
+		t.Errorf("replay mismatch: %X", p)
+	}
+	if err = replay.Verify(); err != nil {
+		t.Error(err)
+	}
+}
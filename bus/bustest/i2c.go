@@ -0,0 +1,70 @@
+package bustest
+
+import (
+	"github.com/zyxar/berry/bus"
+)
+
+// I2C is a scripted bus.I2CConn.
+type I2C struct {
+	script
+}
+
+// NewI2C returns an I2C expecting ops in order.
+func NewI2C(ops ...Op) *I2C {
+	return &I2C{script{ops: ops}}
+}
+
+func (this *I2C) Write(buf ...byte) error {
+	op, err := this.expect(WRITE, buf, 0)
+	if err != nil {
+		return err
+	}
+	return op.Err
+}
+
+func (this *I2C) Read(b []byte) error {
+	op, err := this.expect(READ, nil, len(b))
+	if err != nil {
+		return err
+	}
+	copy(b, op.R)
+	return op.Err
+}
+
+func (this *I2C) Tx(w, r []byte) error {
+	op, err := this.expect(TX, w, len(r))
+	if err != nil {
+		return err
+	}
+	copy(r, op.R)
+	return op.Err
+}
+
+// I2CRecorder is a bus.I2CConn recording every op performed on conn.
+type I2CRecorder struct {
+	Recorder
+	conn bus.I2CConn
+}
+
+// RecordI2C wraps conn in a recorder.
+func RecordI2C(conn bus.I2CConn) *I2CRecorder {
+	return &I2CRecorder{conn: conn}
+}
+
+func (this *I2CRecorder) Write(buf ...byte) error {
+	err := this.conn.Write(buf...)
+	this.record(WRITE, buf, nil, err)
+	return err
+}
+
+func (this *I2CRecorder) Read(b []byte) error {
+	err := this.conn.Read(b)
+	this.record(READ, nil, b, err)
+	return err
+}
+
+func (this *I2CRecorder) Tx(w, r []byte) error {
+	err := this.conn.Tx(w, r)
+	this.record(TX, w, r, err)
+	return err
+}
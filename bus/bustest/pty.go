@@ -0,0 +1,38 @@
+//go:build linux
+// +build linux
+
+package bustest
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/zyxar/berry/sys"
+)
+
+// OpenPty opens a new pseudo terminal, returning its master side and the path
+// of its slave side, which can be opened as a serial port.
+func OpenPty() (master *os.File, slave string, err error) {
+	if master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0); err != nil {
+		return
+	}
+	rc, err := master.SyscallConn()
+	if err != nil {
+		master.Close()
+		return
+	}
+	var n uint32
+	rc.Control(func(fd uintptr) {
+		if err = sys.IoctlWrite(fd, syscall.TIOCSPTLCK, int32(0)); err != nil {
+			return
+		}
+		n, err = sys.IoctlRead[uint32](fd, syscall.TIOCGPTN)
+	})
+	if err != nil {
+		master.Close()
+		return
+	}
+	slave = fmt.Sprintf("/dev/pts/%d", n)
+	return
+}
@@ -0,0 +1,94 @@
+package bustest
+
+import (
+	"errors"
+
+	"github.com/zyxar/berry/bus"
+)
+
+var (
+	errClosed = errors.New("spi closed")
+)
+
+// SPI is a scripted bus.SPIBus. Like spidev, Read, Write and WriteAndRead
+// are each one full duplex XFER op, overwriting p with what was clocked in.
+type SPI struct {
+	script
+	closed bool // guarded by script.m
+}
+
+// NewSPI returns an SPI expecting ops in order.
+func NewSPI(ops ...Op) *SPI {
+	return &SPI{script: script{ops: ops}}
+}
+
+func (this *SPI) isClosed() bool {
+	defer this.m.Unlock()
+	this.m.Lock()
+	return this.closed
+}
+
+func (this *SPI) WriteAndRead(p []byte) (n int, err error) {
+	if this.isClosed() {
+		err = errClosed
+		return
+	}
+	op, err := this.expect(XFER, p, 0)
+	if err != nil {
+		return
+	}
+	copy(p, op.R)
+	n, err = len(p), op.Err
+	return
+}
+
+func (this *SPI) Read(p []byte) (n int, err error) {
+	return this.WriteAndRead(p)
+}
+
+func (this *SPI) Write(p []byte) (n int, err error) {
+	return this.WriteAndRead(p)
+}
+
+func (this *SPI) Close() error {
+	this.m.Lock()
+	this.closed = true
+	this.m.Unlock()
+	return nil
+}
+
+// SPIRecorder is a bus.SPIBus recording every transfer performed on dev.
+type SPIRecorder struct {
+	Recorder
+	dev bus.SPIBus
+}
+
+// RecordSPI wraps dev in a recorder.
+func RecordSPI(dev bus.SPIBus) *SPIRecorder {
+	return &SPIRecorder{dev: dev}
+}
+
+func (this *SPIRecorder) WriteAndRead(p []byte) (n int, err error) {
+	w := append([]byte(nil), p...)
+	n, err = this.dev.WriteAndRead(p)
+	this.record(XFER, w, p, err)
+	return
+}
+
+func (this *SPIRecorder) Read(p []byte) (n int, err error) {
+	w := append([]byte(nil), p...)
+	n, err = this.dev.Read(p)
+	this.record(XFER, w, p, err)
+	return
+}
+
+func (this *SPIRecorder) Write(p []byte) (n int, err error) {
+	w := append([]byte(nil), p...)
+	n, err = this.dev.Write(p)
+	this.record(XFER, w, p, err)
+	return
+}
+
+func (this *SPIRecorder) Close() error {
+	return this.dev.Close()
+}
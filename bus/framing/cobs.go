@@ -0,0 +1,107 @@
+package framing
+
+import (
+	"bufio"
+	"io"
+)
+
+// COBS frames with consistent overhead byte stuffing, delimiting frames by a
+// zero byte on both sides. Empty frames are skipped. A frame cut by a read
+// error, such as a timeout, is resumed by the next ReadFrame.
+type COBS struct {
+	w     io.Writer
+	r     *bufio.Reader
+	max   int
+	enc   []byte // encoded frame being read
+	large bool
+}
+
+// NewCOBS frames rw, limiting payloads to max bytes (MAX_FRAME_SIZE if 0).
+func NewCOBS(rw io.ReadWriter, max int) *COBS {
+	return &COBS{w: rw, r: bufio.NewReader(rw), max: limit(max)}
+}
+
+// cobsSize returns the encoded size of n bytes, without delimiters.
+func cobsSize(n int) int {
+	return n + n/254 + 1
+}
+
+// cobsEncode appends the encoding of p to dst.
+func cobsEncode(dst, p []byte) []byte {
+	at := len(dst)
+	dst = append(dst, 0)
+	code := byte(1)
+	for _, b := range p {
+		if b != 0 {
+			dst = append(dst, b)
+			code++
+			if code != 0xFF {
+				continue
+			}
+		}
+		dst[at] = code
+		at = len(dst)
+		dst = append(dst, 0)
+		code = 1
+	}
+	dst[at] = code
+	return dst
+}
+
+// cobsDecode appends the decoding of p, which holds no zero bytes, to dst.
+func cobsDecode(dst, p []byte) ([]byte, error) {
+	for i := 0; i < len(p); {
+		code := int(p[i])
+		i++
+		if i+code-1 > len(p) {
+			return nil, ErrInvalidFrame
+		}
+		dst = append(dst, p[i:i+code-1]...)
+		i += code - 1
+		if code != 0xFF && i < len(p) {
+			dst = append(dst, 0)
+		}
+	}
+	return dst, nil
+}
+
+func (this *COBS) WriteFrame(p []byte) error {
+	if len(p) > this.max {
+		return ErrFrameTooLarge
+	}
+	buf := make([]byte, 0, cobsSize(len(p))+2)
+	buf = append(buf, 0)
+	buf = cobsEncode(buf, p)
+	buf = append(buf, 0)
+	_, err := this.w.Write(buf)
+	return err
+}
+
+func (this *COBS) ReadFrame() (p []byte, err error) {
+	for {
+		var b byte
+		if b, err = this.r.ReadByte(); err != nil {
+			return nil, err
+		}
+		if b != 0 {
+			if len(this.enc) == cobsSize(this.max) {
+				this.large = true
+			} else {
+				this.enc = append(this.enc, b)
+			}
+			continue
+		}
+		enc, large := this.enc, this.large
+		this.enc, this.large = nil, false
+		if large {
+			return nil, ErrFrameTooLarge
+		}
+		if len(enc) == 0 {
+			continue
+		}
+		if p, err = cobsDecode(nil, enc); err == nil && len(p) > this.max {
+			p, err = nil, ErrFrameTooLarge
+		}
+		return
+	}
+}
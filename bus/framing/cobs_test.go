@@ -0,0 +1,56 @@
+package framing
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCOBSEncoding(t *testing.T) {
+	seq := make([]byte, 254)
+	for i := range seq {
+		seq[i] = byte(i + 1)
+	}
+	for _, c := range []struct{ in, out []byte }{
+		{[]byte{}, []byte{0x01}},
+		{[]byte{0x00}, []byte{0x01, 0x01}},
+		{[]byte{0x00, 0x00}, []byte{0x01, 0x01, 0x01}},
+		{[]byte{0x11, 0x22, 0x00, 0x33}, []byte{0x03, 0x11, 0x22, 0x02, 0x33}},
+		{[]byte{0x11, 0x00, 0x00, 0x00}, []byte{0x02, 0x11, 0x01, 0x01, 0x01}},
+		{seq, append(append([]byte{0xFF}, seq...), 0x01)},
+		{append([]byte{0x00}, seq...), append(append([]byte{0x01, 0xFF}, seq...), 0x01)},
+	} {
+		enc := cobsEncode(nil, c.in)
+		if !bytes.Equal(enc, c.out) {
+			t.Errorf("encoding of %X mismatch: %X", c.in, enc)
+		}
+		if len(enc) > cobsSize(len(c.in)) {
+			t.Errorf("encoding of %X exceeds %d bytes", c.in, cobsSize(len(c.in)))
+		}
+		dec, err := cobsDecode(nil, enc)
+		if err != nil || !bytes.Equal(dec, c.in) {
+			t.Errorf("decoding of %X mismatch: %X, %v", enc, dec, err)
+		}
+	}
+}
+
+func TestCOBSResync(t *testing.T) {
+	stream := []byte{
+		0x03, 0x11, // partial frame from before we started listening
+		0x00, 0x00,
+		0x02, 0x11, 0x00, // good
+		0x05, 0x11, 0x22, 0x00, // code past the end
+		0x06, 1, 2, 3, 4, 5, 0x00, // too large
+		0x05, 1, 2, 3, 4, 0x00,
+		0x02, 0x11,
+	}
+	f := NewCOBS(bytes.NewBuffer(stream), 4)
+	expect(t, "cobs", f,
+		ErrInvalidFrame,
+		[]byte{0x11},
+		ErrInvalidFrame,
+		ErrFrameTooLarge,
+		[]byte{1, 2, 3, 4},
+		io.EOF,
+	)
+}
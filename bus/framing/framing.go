@@ -0,0 +1,49 @@
+// Package framing splits byte streams, such as serial ports and SPI links,
+// into frames.
+package framing
+
+import (
+	"errors"
+)
+
+const MAX_FRAME_SIZE = 1024 // default limit on the payload of a frame
+
+var (
+	ErrFrameTooLarge = errors.New("framing: frame too large")
+	ErrInvalidFrame  = errors.New("framing: invalid frame")
+	ErrCRC           = errors.New("framing: crc mismatch")
+
+	ErrInvalidChecksum = errors.New("framing: invalid checksum")
+)
+
+// Framer reads and writes whole frames. ReadFrame reports each rejected frame
+// with ErrFrameTooLarge, ErrInvalidFrame or ErrCRC and resynchronizes on the
+// next one, so reading may go on after those errors.
+type Framer interface {
+	ReadFrame() ([]byte, error)
+	WriteFrame(p []byte) error
+}
+
+// CRC16 computes the CRC-16/CCITT-FALSE of p (polynomial 0x1021, initial
+// value 0xFFFF).
+func CRC16(p []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range p {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func limit(max int) int {
+	if max <= 0 {
+		return MAX_FRAME_SIZE
+	}
+	return max
+}
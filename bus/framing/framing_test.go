@@ -0,0 +1,148 @@
+package framing
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// payloads covers delimiters, escapes and runs around the COBS block size.
+func payloads() [][]byte {
+	ps := [][]byte{
+		{0x01},
+		{0x00},
+		{0x00, 0x00},
+		{SLIP_END, SLIP_ESC, SLIP_ESC_END, SLIP_ESC_ESC},
+		{LENGTH_SYNC0, LENGTH_SYNC1, 0x00, 0x01},
+	}
+	for _, n := range []int{253, 254, 255, 508, 1000} {
+		p := make([]byte, n)
+		for i := range p {
+			p[i] = byte(i%255 + 1)
+		}
+		ps = append(ps, p)
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		p := make([]byte, 1+r.Intn(MAX_FRAME_SIZE))
+		r.Read(p)
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// roundTrip writes every payload through f and reads them back.
+func roundTrip(t *testing.T, name string, f Framer) {
+	ps := payloads()
+	for _, p := range ps {
+		if err := f.WriteFrame(p); err != nil {
+			t.Fatalf("%s: write failed: %v", name, err)
+		}
+	}
+	for i, p := range ps {
+		q, err := f.ReadFrame()
+		if err != nil || !bytes.Equal(p, q) {
+			t.Fatalf("%s: frame %d mismatch: %v", name, i, err)
+		}
+	}
+	if _, err := f.ReadFrame(); err != io.EOF {
+		t.Errorf("%s: EOF expected: %v", name, err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	roundTrip(t, "slip", NewSLIP(new(bytes.Buffer), 0))
+	roundTrip(t, "cobs", NewCOBS(new(bytes.Buffer), 0))
+	roundTrip(t, "crc16", newLength(t, new(bytes.Buffer), CRC16_CCITT, 0))
+	roundTrip(t, "crc32", newLength(t, new(bytes.Buffer), CRC32_IEEE, 0))
+}
+
+// timeouts reads chunks in turn, failing with os.ErrDeadlineExceeded for the
+// nil ones, as a serial port with a read timeout does.
+type timeouts struct {
+	chunks       [][]byte
+	bytes.Buffer // written frames
+}
+
+func (this *timeouts) Read(p []byte) (n int, err error) {
+	if len(this.chunks) == 0 {
+		return 0, io.EOF
+	}
+	c := this.chunks[0]
+	if c == nil {
+		this.chunks = this.chunks[1:]
+		return 0, os.ErrDeadlineExceeded
+	}
+	n = copy(p, c)
+	if this.chunks[0] = c[n:]; len(this.chunks[0]) == 0 {
+		this.chunks = this.chunks[1:]
+	}
+	return
+}
+
+func TestReadTimeout(t *testing.T) {
+	for name, f := range map[string]func(rw io.ReadWriter) Framer{
+		"slip":  func(rw io.ReadWriter) Framer { return NewSLIP(rw, 0) },
+		"cobs":  func(rw io.ReadWriter) Framer { return NewCOBS(rw, 0) },
+		"crc16": func(rw io.ReadWriter) Framer { return newLength(t, rw, CRC16_CCITT, 0) },
+		"crc32": func(rw io.ReadWriter) Framer { return newLength(t, rw, CRC32_IEEE, 0) },
+	} {
+		var rw timeouts
+		w := f(&rw)
+		ps := [][]byte{{0x01, 0x02, 0x03, 0x04, 0x05}, {SLIP_END, 0x00, LENGTH_SYNC0, LENGTH_SYNC1}}
+		for _, p := range ps {
+			w.WriteFrame(p)
+		}
+		stream := rw.Bytes()
+		for cut := 1; cut < len(stream); cut++ {
+			rw.chunks = [][]byte{stream[:cut], nil, stream[cut:]}
+			r := f(&rw)
+			for i, p := range ps {
+				q, err := r.ReadFrame()
+				if err == os.ErrDeadlineExceeded {
+					q, err = r.ReadFrame()
+				}
+				if err != nil || !bytes.Equal(p, q) {
+					t.Fatalf("%s: cut at %d: frame %d mismatch: %X %v", name, cut, i, q, err)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteTooLarge(t *testing.T) {
+	for name, f := range map[string]Framer{
+		"slip":   NewSLIP(new(bytes.Buffer), 8),
+		"cobs":   NewCOBS(new(bytes.Buffer), 8),
+		"length": newLength(t, new(bytes.Buffer), CRC16_CCITT, 8),
+	} {
+		if err := f.WriteFrame(make([]byte, 9)); err != ErrFrameTooLarge {
+			t.Errorf("%s: oversized frame written: %v", name, err)
+		}
+	}
+}
+
+// expect reads from f, checking each result against want, nil meaning an error.
+func expect(t *testing.T, name string, f Framer, want ...interface{}) {
+	for i, w := range want {
+		p, err := f.ReadFrame()
+		switch w := w.(type) {
+		case error:
+			if err != w {
+				t.Errorf("%s: read %d: %v expected, got %X, %v", name, i, w, p, err)
+			}
+		case []byte:
+			if err != nil || !bytes.Equal(p, w) {
+				t.Errorf("%s: read %d: %X expected, got %X, %v", name, i, w, p, err)
+			}
+		}
+	}
+}
+
+func TestCRC16(t *testing.T) {
+	if crc := CRC16([]byte("123456789")); crc != 0x29B1 {
+		t.Errorf("crc mismatch: %04X", crc)
+	}
+}
@@ -0,0 +1,107 @@
+package framing
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	LENGTH_SYNC0 = 0x55
+	LENGTH_SYNC1 = 0xAA
+)
+
+// Checksum selects the check of a length-prefixed frame; its value is the
+// size in bytes.
+type Checksum int
+
+const (
+	CRC16_CCITT Checksum = 2
+	CRC32_IEEE  Checksum = 4
+)
+
+// Length frames as SYNC0 SYNC1 <length:16> <payload> <crc>, the length and
+// crc in big endian, and the crc covering length and payload. After a bad
+// frame, reading hunts for the next sync bytes from the one after SYNC0. A
+// frame cut by a read error, such as a timeout, stays buffered for the next
+// ReadFrame.
+type Length struct {
+	w   io.Writer
+	r   *bufio.Reader
+	sum Checksum
+	max int
+}
+
+// NewLength frames rw, limiting payloads to max bytes (MAX_FRAME_SIZE if 0,
+// at most 65535). sum must be CRC16_CCITT or CRC32_IEEE.
+func NewLength(rw io.ReadWriter, sum Checksum, max int) (*Length, error) {
+	if sum != CRC16_CCITT && sum != CRC32_IEEE {
+		return nil, ErrInvalidChecksum
+	}
+	max = limit(max)
+	if max > 0xFFFF {
+		max = 0xFFFF
+	}
+	return &Length{w: rw, r: bufio.NewReaderSize(rw, 4+max+int(sum)), sum: sum, max: max}, nil
+}
+
+// check returns the checksum of p, in big endian.
+func (this *Length) check(p []byte) []byte {
+	sum := make([]byte, this.sum)
+	if this.sum == CRC32_IEEE {
+		binary.BigEndian.PutUint32(sum, crc32.ChecksumIEEE(p))
+	} else {
+		binary.BigEndian.PutUint16(sum, CRC16(p))
+	}
+	return sum
+}
+
+func (this *Length) WriteFrame(p []byte) error {
+	if len(p) > this.max {
+		return ErrFrameTooLarge
+	}
+	buf := make([]byte, 4, 4+len(p)+int(this.sum))
+	buf[0], buf[1] = LENGTH_SYNC0, LENGTH_SYNC1
+	binary.BigEndian.PutUint16(buf[2:], uint16(len(p)))
+	buf = append(buf, p...)
+	buf = append(buf, this.check(buf[2:])...)
+	_, err := this.w.Write(buf)
+	return err
+}
+
+func (this *Length) ReadFrame() (p []byte, err error) {
+	for {
+		var head []byte
+		if head, err = this.r.Peek(1); err != nil {
+			return
+		}
+		if head[0] != LENGTH_SYNC0 {
+			this.r.Discard(1)
+			continue
+		}
+		if head, err = this.r.Peek(4); err != nil {
+			return
+		}
+		if head[1] != LENGTH_SYNC1 {
+			this.r.Discard(1)
+			continue
+		}
+		n := int(binary.BigEndian.Uint16(head[2:]))
+		if n > this.max {
+			this.r.Discard(1)
+			return nil, ErrFrameTooLarge
+		}
+		var frame []byte
+		if frame, err = this.r.Peek(4 + n + int(this.sum)); err != nil {
+			return
+		}
+		if string(this.check(frame[2:4+n])) != string(frame[4+n:]) {
+			this.r.Discard(1)
+			return nil, ErrCRC
+		}
+		p = append([]byte(nil), frame[4:4+n]...)
+		this.r.Discard(len(frame))
+		return
+	}
+}
@@ -0,0 +1,69 @@
+package framing
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func newLength(t *testing.T, rw io.ReadWriter, sum Checksum, max int) *Length {
+	f, err := NewLength(rw, sum, max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestLengthChecksum(t *testing.T) {
+	for _, sum := range []Checksum{0, 1, 3, 8} {
+		if _, err := NewLength(new(bytes.Buffer), sum, 0); err != ErrInvalidChecksum {
+			t.Errorf("checksum %d: %v expected, got %v", sum, ErrInvalidChecksum, err)
+		}
+	}
+}
+
+func TestLengthEncoding(t *testing.T) {
+	var buf bytes.Buffer
+	newLength(t, &buf, CRC16_CCITT, 0).WriteFrame([]byte("123456789"))
+	want := append([]byte{LENGTH_SYNC0, LENGTH_SYNC1, 0x00, 0x09}, "123456789"...)
+	crc := CRC16(want[2:])
+	want = append(want, byte(crc>>8), byte(crc))
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("encoding mismatch: %X", buf.Bytes())
+	}
+	buf.Reset()
+	newLength(t, &buf, CRC32_IEEE, 0).WriteFrame(nil)
+	if !bytes.Equal(buf.Bytes(), []byte{LENGTH_SYNC0, LENGTH_SYNC1, 0x00, 0x00, 0x41, 0xD9, 0x12, 0xFF}) {
+		t.Errorf("empty frame encoding mismatch: %X", buf.Bytes())
+	}
+}
+
+func TestLengthResync(t *testing.T) {
+	for _, sum := range []Checksum{CRC16_CCITT, CRC32_IEEE} {
+		var buf bytes.Buffer
+		w := newLength(t, &buf, sum, 0)
+		w.WriteFrame([]byte{LENGTH_SYNC0, LENGTH_SYNC1, 0x00, 0x01, 0x02})
+		good := append([]byte(nil), buf.Bytes()...)
+		buf.Reset()
+		w.WriteFrame([]byte{0x42, 0x43})
+		corrupt := append([]byte(nil), buf.Bytes()...)
+		corrupt[len(corrupt)-1] ^= 1
+
+		var stream []byte
+		stream = append(stream, 0x12, LENGTH_SYNC0, 0x34, LENGTH_SYNC0) // noise
+		stream = append(stream, good[5:]...)                            // tail of a frame, syncing on the noise
+		stream = append(stream, corrupt...)
+		stream = append(stream, LENGTH_SYNC0, LENGTH_SYNC1, 0xFF, 0xFF) // too large
+		stream = append(stream, good...)
+		stream = append(stream, good[:len(good)-1]...) // truncated
+
+		f := newLength(t, bytes.NewBuffer(stream), sum, 16)
+		expect(t, "length", f,
+			ErrCRC,
+			ErrCRC,
+			ErrFrameTooLarge,
+			good[4:len(good)-int(sum)],
+			io.EOF,
+		)
+	}
+}
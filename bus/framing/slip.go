@@ -0,0 +1,98 @@
+package framing
+
+import (
+	"bufio"
+	"io"
+)
+
+const (
+	SLIP_END     = 0xC0
+	SLIP_ESC     = 0xDB
+	SLIP_ESC_END = 0xDC
+	SLIP_ESC_ESC = 0xDD
+) // RFC 1055
+
+// SLIP frames with RFC 1055 serial line IP encoding. Frames are delimited by
+// END on both sides, so garbage ends at the next delimiter; empty frames are
+// skipped. A frame cut by a read error, such as a timeout, is resumed by the
+// next ReadFrame.
+type SLIP struct {
+	w   io.Writer
+	r   *bufio.Reader
+	max int
+	p   []byte // frame being read
+	esc bool
+	bad error
+}
+
+// NewSLIP frames rw, limiting payloads to max bytes (MAX_FRAME_SIZE if 0).
+func NewSLIP(rw io.ReadWriter, max int) *SLIP {
+	return &SLIP{w: rw, r: bufio.NewReader(rw), max: limit(max)}
+}
+
+func (this *SLIP) WriteFrame(p []byte) error {
+	if len(p) > this.max {
+		return ErrFrameTooLarge
+	}
+	buf := make([]byte, 0, 2*len(p)+2)
+	buf = append(buf, SLIP_END)
+	for _, b := range p {
+		switch b {
+		case SLIP_END:
+			buf = append(buf, SLIP_ESC, SLIP_ESC_END)
+		case SLIP_ESC:
+			buf = append(buf, SLIP_ESC, SLIP_ESC_ESC)
+		default:
+			buf = append(buf, b)
+		}
+	}
+	buf = append(buf, SLIP_END)
+	_, err := this.w.Write(buf)
+	return err
+}
+
+func (this *SLIP) ReadFrame() (p []byte, err error) {
+	for {
+		var b byte
+		if b, err = this.r.ReadByte(); err != nil {
+			return nil, err
+		}
+		if b == SLIP_END {
+			p, err = this.p, this.bad
+			if this.esc {
+				err = ErrInvalidFrame
+			}
+			this.p, this.esc, this.bad = nil, false, nil
+			if err != nil {
+				return nil, err
+			}
+			if len(p) > 0 {
+				return
+			}
+			continue
+		}
+		if this.bad != nil {
+			continue
+		}
+		if this.esc {
+			this.esc = false
+			switch b {
+			case SLIP_ESC_END:
+				b = SLIP_END
+			case SLIP_ESC_ESC:
+				b = SLIP_ESC
+			default:
+				this.bad = ErrInvalidFrame
+				continue
+			}
+		} else if b == SLIP_ESC {
+			this.esc = true
+			continue
+		}
+		if len(this.p) == this.max {
+			this.bad = ErrFrameTooLarge
+			continue
+		}
+		this.p = append(this.p, b)
+	}
+}
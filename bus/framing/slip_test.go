@@ -0,0 +1,36 @@
+package framing
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestSLIPEncoding(t *testing.T) {
+	var buf bytes.Buffer
+	NewSLIP(&buf, 0).WriteFrame([]byte{0x01, SLIP_END, 0x02, SLIP_ESC})
+	want := []byte{SLIP_END, 0x01, SLIP_ESC, SLIP_ESC_END, 0x02, SLIP_ESC, SLIP_ESC_ESC, SLIP_END}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("encoding mismatch: %X", buf.Bytes())
+	}
+}
+
+func TestSLIPResync(t *testing.T) {
+	stream := []byte{
+		SLIP_END, SLIP_END, 0x01, SLIP_END, // empty frame skipped
+		0x02, SLIP_ESC, 0x42, 0x03, SLIP_END, // bad escape
+		0x04, SLIP_ESC, SLIP_END, // escape cut by a delimiter
+		1, 2, 3, 4, 5, SLIP_END, // too large
+		1, 2, 3, 4, SLIP_END,
+		0x05,
+	}
+	f := NewSLIP(bytes.NewBuffer(stream), 4)
+	expect(t, "slip", f,
+		[]byte{0x01},
+		ErrInvalidFrame,
+		ErrInvalidFrame,
+		ErrFrameTooLarge,
+		[]byte{1, 2, 3, 4},
+		io.EOF,
+	)
+}
@@ -66,10 +66,11 @@ package bus
 // based on https://github.com/davecheney/i2c/blob/master/i2c.go
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"runtime"
-	"unsafe"
+	"time"
 
 	"github.com/zyxar/berry/sys"
 )
@@ -91,16 +92,52 @@ const (
 	I2C_RDRW_IOCTL_MAX_MSGS = 42
 ) // from <linux/i2c-dev.h>
 
+var (
+	errPECUnsupported = errors.New("pec unsupported by adapter")
+)
+
+// I2CConn is a connection to a single i2c device, implemented by *I2C and
+// *I2CDevice.
+type I2CConn interface {
+	Write(buf ...byte) error
+	Read(b []byte) error
+	Tx(w, r []byte) error
+}
+
 // I2C represents a connection to an i2c device.
 type I2C struct {
-	rc   *os.File
-	addr uint
-	dev  uint
-	mask uint64
+	rc     *os.File
+	addr   uint
+	dev    uint
+	mask   uint64
+	tenbit bool
+}
+
+// I2COptions configures a connection opened by NewI2CWithOptions.
+// Retries and Timeout apply to the whole adapter, not only to this client.
+type I2COptions struct {
+	Retries uint          // times an address is polled when not acknowledging; 0 keeps the adapter default
+	Timeout time.Duration // transfer timeout, rounded up to 10ms; 0 keeps the adapter default
+	Force   bool          // claim the address even if a kernel driver is bound to it
+	TenBit  bool          // use 10-bit addressing
+	Funcs   uint64        // I2C_FUNC_* bits the adapter must provide
 }
 
 // New opens a connection to an i2c device.
+// Addresses above 0x7F switch to 10-bit addressing.
 func NewI2C(addr uint, dev uint) (i *I2C, err error) {
+	return NewI2CWithOptions(addr, dev, &I2COptions{TenBit: addr > 0x7F})
+}
+
+// NewI2CWithOptions opens a connection to an i2c device, configured by opts.
+func NewI2CWithOptions(addr uint, dev uint, opts *I2COptions) (i *I2C, err error) {
+	if opts == nil {
+		opts = &I2COptions{}
+	}
+	if (!opts.TenBit && addr > 0x7F) || addr > 0x3FF {
+		err = fmt.Errorf("address overflow: %d", addr)
+		return
+	}
 	f, err := os.OpenFile(fmt.Sprintf("/dev/i2c-%d", dev), os.O_RDWR, 0600)
 	if err != nil {
 		return
@@ -110,26 +147,45 @@ func NewI2C(addr uint, dev uint) (i *I2C, err error) {
 			f.Close()
 		}
 	}()
-	if addr <= 0x7F {
-		if err = sys.Ioctl(f.Fd(), I2C_TENBIT, 0); err != nil {
+	var v uint // unsigned long
+	if err = sys.IoctlPtr(f.Fd(), I2C_FUNCS, &v); err != nil {
+		return
+	}
+	mask := uint64(v)
+	funcs := opts.Funcs
+	if opts.TenBit {
+		funcs |= I2C_FUNC_10BIT_ADDR
+	}
+	if missing := funcs &^ mask; missing != 0 {
+		err = fmt.Errorf("adapter lacks functionality: 0x%08X", missing)
+		return
+	}
+	var tenbit uintptr
+	if opts.TenBit {
+		tenbit = 1
+	}
+	if err = sys.IoctlValue(f.Fd(), I2C_TENBIT, tenbit); err != nil {
+		return
+	}
+	if opts.Retries > 0 {
+		if err = sys.IoctlValue(f.Fd(), I2C_RETRIES, uintptr(opts.Retries)); err != nil {
 			return
 		}
-	} else if addr <= 0x3FF {
-		if err = sys.Ioctl(f.Fd(), I2C_TENBIT, 1); err != nil {
+	}
+	if opts.Timeout > 0 {
+		jiffies := (opts.Timeout + 10*time.Millisecond - 1) / (10 * time.Millisecond)
+		if err = sys.IoctlValue(f.Fd(), I2C_TIMEOUT, uintptr(jiffies)); err != nil {
 			return
 		}
-	} else {
-		err = fmt.Errorf("address overflow: %d", addr)
-		return
 	}
-	if err = sys.Ioctl(f.Fd(), I2C_SLAVE, uintptr(addr)); err != nil {
-		return
+	slave := uintptr(I2C_SLAVE)
+	if opts.Force {
+		slave = I2C_SLAVE_FORCE
 	}
-	var mask uint64
-	if err = sys.Ioctl(f.Fd(), I2C_FUNCS, uintptr(unsafe.Pointer(&mask))); err != nil {
+	if err = sys.IoctlValue(f.Fd(), slave, uintptr(addr)); err != nil {
 		return
 	}
-	i = &I2C{f, addr, dev, mask}
+	i = &I2C{f, addr, dev, mask, opts.TenBit}
 	runtime.SetFinalizer(i, func(this *I2C) {
 		this.Close()
 	})
@@ -151,6 +207,18 @@ func (this *I2C) Mask() uint64 {
 	return this.mask
 }
 
+// SetPEC enables or disables packet error checking for SMBus transfers.
+func (this *I2C) SetPEC(enable bool) error {
+	var v uintptr
+	if enable {
+		if this.mask&I2C_FUNC_SMBUS_PEC == 0 {
+			return errPECUnsupported
+		}
+		v = 1
+	}
+	return sys.IoctlValue(this.rc.Fd(), I2C_PEC, v)
+}
+
 // Write sends buf to the i2c device.
 func (this *I2C) Write(buf ...byte) error {
 	_, err := this.rc.Write(buf)
@@ -163,6 +231,11 @@ func (this *I2C) Read(b []byte) error {
 	return err
 }
 
+// Tx writes w and then reads into r as one transaction.
+func (this *I2C) Tx(w, r []byte) error {
+	return i2cTx(this.rc, this.addr, this.tenbit, this.mask, w, r)
+}
+
 const I2CCLOCK_CHANGE = 0x0740
 
 func SetBusFreq(hz uint) error {
@@ -174,6 +247,6 @@ func SetBusFreq(hz uint) error {
 		return err
 	}
 	defer f.Close()
-	err = sys.Ioctl(f.Fd(), I2CCLOCK_CHANGE, uintptr(unsafe.Pointer(&hz)))
+	err = sys.IoctlPtr(f.Fd(), I2CCLOCK_CHANGE, &hz)
 	return err
 }
@@ -0,0 +1,130 @@
+package bus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zyxar/berry/sys"
+)
+
+const (
+	i2cClassPath   = "/sys/class/i2c-dev"
+	i2cDevicesPath = "/sys/bus/i2c/devices"
+
+	I2C_ADDR_OFFSET_TEN_BIT = 0xa000 /* sysfs name offset of ten bit clients */
+	I2C_ADDR_OFFSET_SLAVE   = 0x1000 /* sysfs name offset of slave backends */
+)
+
+// I2CAdapter describes an i2c adapter found in sysfs.
+type I2CAdapter struct {
+	Bus     uint
+	Name    string
+	Type    string // "i2c", "smbus", or "unknown" if the adapter could not be opened
+	Mask    uint64 // functionality mask, 0 if unknown
+	Clients []I2CClient
+}
+
+// I2CClient describes a device instantiated by the kernel on an adapter.
+type I2CClient struct {
+	Addr   uint
+	Name   string
+	Driver string // bound kernel driver, empty if none
+	TenBit bool   // Addr is a ten bit address
+	Slave  bool   // the adapter itself responds at Addr, as a slave backend
+}
+
+// I2CAdapters lists the i2c adapters exposed through i2c-dev, together with
+// the client devices the kernel knows about.
+func I2CAdapters() ([]I2CAdapter, error) {
+	return listI2CAdapters("/", adapterMask)
+}
+
+func adapterMask(dev uint) (mask uint64, err error) {
+	f, err := os.OpenFile(fmt.Sprintf("/dev/i2c-%d", dev), os.O_RDWR, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var funcs uint // unsigned long
+	err = sys.IoctlPtr(f.Fd(), I2C_FUNCS, &funcs)
+	mask = uint64(funcs)
+	return
+}
+
+func listI2CAdapters(root string, mask func(uint) (uint64, error)) (adapters []I2CAdapter, err error) {
+	entries, err := ioutil.ReadDir(filepath.Join(root, i2cClassPath))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "i2c-") {
+			continue
+		}
+		n, err := strconv.ParseUint(entry.Name()[4:], 10, 32)
+		if err != nil {
+			continue
+		}
+		a := I2CAdapter{Bus: uint(n), Type: "unknown"}
+		a.Name = readTrimmed(filepath.Join(root, i2cClassPath, entry.Name(), "name"))
+		if m, err := mask(a.Bus); err == nil {
+			a.Mask = m
+			if m&I2C_FUNC_I2C != 0 {
+				a.Type = "i2c"
+			} else {
+				a.Type = "smbus"
+			}
+		}
+		adapters = append(adapters, a)
+	}
+	sort.Slice(adapters, func(i, j int) bool { return adapters[i].Bus < adapters[j].Bus })
+	index := make(map[uint]int)
+	for i := range adapters {
+		index[adapters[i].Bus] = i
+	}
+
+	clients, _ := ioutil.ReadDir(filepath.Join(root, i2cDevicesPath))
+	for _, entry := range clients {
+		var n, addr uint
+		if _, err := fmt.Sscanf(entry.Name(), "%d-%04x", &n, &addr); err != nil {
+			continue
+		}
+		i, ok := index[n]
+		if !ok {
+			continue
+		}
+		dir := filepath.Join(root, i2cDevicesPath, entry.Name())
+		c := I2CClient{
+			Addr:   addr &^ (I2C_ADDR_OFFSET_TEN_BIT | I2C_ADDR_OFFSET_SLAVE),
+			Name:   readTrimmed(filepath.Join(dir, "name")),
+			TenBit: addr&I2C_ADDR_OFFSET_TEN_BIT == I2C_ADDR_OFFSET_TEN_BIT,
+			Slave:  addr&I2C_ADDR_OFFSET_SLAVE != 0,
+		}
+		if link, err := os.Readlink(filepath.Join(dir, "driver")); err == nil {
+			c.Driver = filepath.Base(link)
+		}
+		adapters[i].Clients = append(adapters[i].Clients, c)
+	}
+	for i := range adapters {
+		c := adapters[i].Clients
+		sort.Slice(c, func(i, j int) bool {
+			if c[i].Addr != c[j].Addr {
+				return c[i].Addr < c[j].Addr
+			}
+			return !c[i].Slave && c[j].Slave
+		})
+	}
+	return adapters, nil
+}
+
+func readTrimmed(path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
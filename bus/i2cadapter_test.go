@@ -0,0 +1,91 @@
+package bus
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSysfs(t *testing.T, root, path, content string) {
+	path = filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListI2CAdapters(t *testing.T) {
+	root := t.TempDir()
+	writeSysfs(t, root, "sys/class/i2c-dev/i2c-1/name", "bcm2835 (i2c@7e804000)\n")
+	writeSysfs(t, root, "sys/class/i2c-dev/i2c-0/name", "bcm2835 (i2c@7e205000)\n")
+	writeSysfs(t, root, "sys/class/i2c-dev/i2c-11/name", "i2c-11-mux (chan_id 0)\n")
+	writeSysfs(t, root, "sys/bus/i2c/devices/1-0068/name", "ds1307\n")
+	writeSysfs(t, root, "sys/bus/i2c/devices/1-0020/name", "mcp23008\n")
+	writeSysfs(t, root, "sys/bus/i2c/devices/0-0050/name", "24c32\n")
+	writeSysfs(t, root, "sys/bus/i2c/devices/0-a123/name", "tenbit\n")
+	writeSysfs(t, root, "sys/bus/i2c/devices/0-1050/name", "slave-24c02\n")
+	writeSysfs(t, root, "sys/bus/i2c/devices/i2c-1/name", "bcm2835 (i2c@7e804000)\n")
+	os.MkdirAll(filepath.Join(root, "sys/bus/i2c/drivers/rtc-ds1307"), 0755)
+	if err := os.Symlink("../../../bus/i2c/drivers/rtc-ds1307",
+		filepath.Join(root, "sys/bus/i2c/devices/1-0068/driver")); err != nil {
+		t.Fatal(err)
+	}
+
+	masks := map[uint]uint64{
+		0: I2C_FUNC_I2C | I2C_FUNC_SMBUS_QUICK,
+		1: I2C_FUNC_SMBUS_BYTE,
+	}
+	adapters, err := listI2CAdapters(root, func(n uint) (uint64, error) {
+		if m, ok := masks[n]; ok {
+			return m, nil
+		}
+		return 0, errors.New("no such adapter")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adapters) != 3 {
+		t.Fatalf("adapter number mismatch: %d", len(adapters))
+	}
+	for i, expected := range []struct {
+		bus     uint
+		name    string
+		typ     string
+		clients int
+	}{
+		{0, "bcm2835 (i2c@7e205000)", "i2c", 3},
+		{1, "bcm2835 (i2c@7e804000)", "smbus", 2},
+		{11, "i2c-11-mux (chan_id 0)", "unknown", 0},
+	} {
+		a := adapters[i]
+		if a.Bus != expected.bus || a.Name != expected.name || a.Type != expected.typ {
+			t.Errorf("adapter mismatch: %+v", a)
+		}
+		if len(a.Clients) != expected.clients {
+			t.Errorf("client number mismatch on i2c-%d: %d", a.Bus, len(a.Clients))
+		}
+	}
+	if c := adapters[0].Clients; len(c) == 3 {
+		if c[0].Addr != 0x50 || c[0].Name != "24c32" || c[0].TenBit || c[0].Slave {
+			t.Errorf("client mismatch: %+v", c[0])
+		}
+		if c[1].Addr != 0x50 || c[1].Name != "slave-24c02" || c[1].TenBit || !c[1].Slave {
+			t.Errorf("client mismatch: %+v", c[1])
+		}
+		if c[2].Addr != 0x123 || c[2].Name != "tenbit" || !c[2].TenBit || c[2].Slave {
+			t.Errorf("client mismatch: %+v", c[2])
+		}
+	}
+	if c := adapters[1].Clients; len(c) == 2 {
+		if c[0].Addr != 0x20 || c[0].Name != "mcp23008" || c[0].Driver != "" {
+			t.Errorf("client mismatch: %+v", c[0])
+		}
+		if c[1].Addr != 0x68 || c[1].Name != "ds1307" || c[1].Driver != "rtc-ds1307" {
+			t.Errorf("client mismatch: %+v", c[1])
+		}
+	}
+}
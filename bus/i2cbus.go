@@ -0,0 +1,252 @@
+package bus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/zyxar/berry/sys"
+)
+
+const (
+	I2C_M_RD  = 0x0001 /* read data, from slave to master */
+	I2C_M_TEN = 0x0010 /* this is a ten bit chip address */
+)
+
+var (
+	errBusClosed = errors.New("i2c bus closed")
+)
+
+// I2CBus is a handle to an i2c adapter, shared by all of its device clients.
+// Transactions from all clients are serialized by a bus-level lock.
+type I2CBus struct {
+	m      sync.Mutex
+	rc     *os.File
+	dev    uint
+	mask   uint64
+	refs   int
+	addr   uint // address currently selected on rc
+	force  bool
+	tenbit bool
+}
+
+// I2CDevice is a lightweight client for one address on a shared I2CBus.
+type I2CDevice struct {
+	bus    *I2CBus
+	addr   uint
+	force  bool
+	tenbit bool
+}
+
+type i2cMsg struct {
+	addr  uint16
+	flags uint16
+	len   uint16
+	buf   unsafe.Pointer
+}
+
+type i2cRdwrIoctlData struct {
+	msgs  unsafe.Pointer
+	nmsgs uint32
+}
+
+var (
+	busTable = make(map[uint]*I2CBus)
+	busMutex = sync.Mutex{} // protect table
+)
+
+// OpenI2CBus opens /dev/i2c-<dev>, or returns the handle already opened in
+// this process. Each call must be paired with Close.
+func OpenI2CBus(dev uint) (*I2CBus, error) {
+	defer busMutex.Unlock()
+	busMutex.Lock()
+	if b, ok := busTable[dev]; ok {
+		b.refs++
+		return b, nil
+	}
+	f, err := os.OpenFile(fmt.Sprintf("/dev/i2c-%d", dev), os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	var funcs uint // unsigned long
+	if err = sys.IoctlPtr(f.Fd(), I2C_FUNCS, &funcs); err != nil {
+		f.Close()
+		return nil, err
+	}
+	b := &I2CBus{rc: f, dev: dev, mask: uint64(funcs), refs: 1, addr: ^uint(0)}
+	busTable[dev] = b
+	return b, nil
+}
+
+// Close releases the handle; the adapter is closed when the last user is gone.
+func (this *I2CBus) Close() (err error) {
+	defer busMutex.Unlock()
+	busMutex.Lock()
+	if this.refs == 0 {
+		return
+	}
+	if this.refs--; this.refs > 0 {
+		return
+	}
+	delete(busTable, this.dev)
+	this.m.Lock()
+	err = this.rc.Close()
+	this.rc = nil
+	this.m.Unlock()
+	return
+}
+
+// Dev returns the adapter number.
+func (this *I2CBus) Dev() uint {
+	return this.dev
+}
+
+// Mask returns the adapter functionality mask (I2C_FUNC_*).
+func (this *I2CBus) Mask() uint64 {
+	return this.mask
+}
+
+// Device returns a client for addr. Only Force, TenBit and Funcs of opts are
+// per client; Retries and Timeout are applied to the adapter.
+func (this *I2CBus) Device(addr uint, opts *I2COptions) (d *I2CDevice, err error) {
+	if opts == nil {
+		opts = &I2COptions{TenBit: addr > 0x7F}
+	}
+	if (!opts.TenBit && addr > 0x7F) || addr > 0x3FF {
+		err = fmt.Errorf("address overflow: %d", addr)
+		return
+	}
+	funcs := opts.Funcs
+	if opts.TenBit {
+		funcs |= I2C_FUNC_10BIT_ADDR
+	}
+	if missing := funcs &^ this.mask; missing != 0 {
+		err = fmt.Errorf("adapter lacks functionality: 0x%08X", missing)
+		return
+	}
+	if opts.Retries > 0 || opts.Timeout > 0 {
+		defer this.m.Unlock()
+		this.m.Lock()
+		if this.rc == nil {
+			err = errBusClosed
+			return
+		}
+		if opts.Retries > 0 {
+			if err = sys.IoctlValue(this.rc.Fd(), I2C_RETRIES, uintptr(opts.Retries)); err != nil {
+				return
+			}
+		}
+		if opts.Timeout > 0 {
+			jiffies := (opts.Timeout + 10*time.Millisecond - 1) / (10 * time.Millisecond)
+			if err = sys.IoctlValue(this.rc.Fd(), I2C_TIMEOUT, uintptr(jiffies)); err != nil {
+				return
+			}
+		}
+	}
+	d = &I2CDevice{this, addr, opts.Force, opts.TenBit}
+	return
+}
+
+// selectDevice points the adapter fd at d; the bus lock must be held.
+func (this *I2CBus) selectDevice(d *I2CDevice) (err error) {
+	if this.rc == nil {
+		return errBusClosed
+	}
+	if this.addr == d.addr && this.force == d.force && this.tenbit == d.tenbit {
+		return
+	}
+	this.addr = ^uint(0)
+	var tenbit uintptr
+	if d.tenbit {
+		tenbit = 1
+	}
+	if err = sys.IoctlValue(this.rc.Fd(), I2C_TENBIT, tenbit); err != nil {
+		return
+	}
+	slave := uintptr(I2C_SLAVE)
+	if d.force {
+		slave = I2C_SLAVE_FORCE
+	}
+	if err = sys.IoctlValue(this.rc.Fd(), slave, uintptr(d.addr)); err != nil {
+		return
+	}
+	this.addr, this.force, this.tenbit = d.addr, d.force, d.tenbit
+	return
+}
+
+// Bus returns the bus the device is attached to.
+func (this *I2CDevice) Bus() *I2CBus {
+	return this.bus
+}
+
+// Addr returns the device address.
+func (this *I2CDevice) Addr() uint {
+	return this.addr
+}
+
+// Do runs fn with the bus locked and the device selected, so that several
+// calls (e.g. SMBus* functions on fd) form a single transaction.
+func (this *I2CDevice) Do(fn func(fd uintptr) error) error {
+	defer this.bus.m.Unlock()
+	this.bus.m.Lock()
+	if err := this.bus.selectDevice(this); err != nil {
+		return err
+	}
+	return fn(this.bus.rc.Fd())
+}
+
+// Write sends buf to the i2c device.
+func (this *I2CDevice) Write(buf ...byte) error {
+	return this.Do(func(uintptr) error {
+		_, err := this.bus.rc.Write(buf)
+		return err
+	})
+}
+
+// Read receives bytes from the i2c device.
+func (this *I2CDevice) Read(b []byte) error {
+	return this.Do(func(uintptr) error {
+		_, err := this.bus.rc.Read(b)
+		return err
+	})
+}
+
+// Tx writes w and then reads into r as one transaction. A combined transfer
+// with a repeated start is used if the adapter supports plain i2c.
+func (this *I2CDevice) Tx(w, r []byte) error {
+	return this.Do(func(uintptr) error {
+		return i2cTx(this.bus.rc, this.addr, this.tenbit, this.bus.mask, w, r)
+	})
+}
+
+func i2cTx(f *os.File, addr uint, tenbit bool, mask uint64, w, r []byte) error {
+	if mask&I2C_FUNC_I2C == 0 || len(w) == 0 || len(r) == 0 {
+		if len(w) > 0 {
+			if _, err := f.Write(w); err != nil {
+				return err
+			}
+		}
+		if len(r) > 0 {
+			if _, err := f.Read(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var flags uint16
+	if tenbit {
+		flags |= I2C_M_TEN
+	}
+	msgs := [2]i2cMsg{
+		{uint16(addr), flags, uint16(len(w)), unsafe.Pointer(&w[0])},
+		{uint16(addr), flags | I2C_M_RD, uint16(len(r)), unsafe.Pointer(&r[0])},
+	}
+	data := i2cRdwrIoctlData{unsafe.Pointer(&msgs[0]), 2}
+	err := sys.IoctlPtr(f.Fd(), I2C_RDWR, &data)
+	runtime.KeepAlive(msgs)
+	return err
+}
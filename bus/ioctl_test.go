@@ -0,0 +1,87 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le && !ppc64 && !ppc64le
+// +build linux,!mips,!mipsle,!mips64,!mips64le,!ppc64,!ppc64le
+
+package bus
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/zyxar/berry/sys"
+)
+
+// layout is the size and field offsets of a struct as the kernel sees it.
+type layout struct {
+	name    string
+	size    uintptr
+	offsets []uintptr
+	want    [2][]uintptr // size then offsets, for 32-bit and 64-bit pointers
+}
+
+func TestIoctlLayouts(t *testing.T) {
+	var (
+		smbus  smbusIoctlData
+		msg    i2cMsg
+		rdwr   i2cRdwrIoctlData
+		spi    spiIoctlTransfer
+		term   termios2
+		rs485  serialRS485
+		arch64 = unsafe.Sizeof(uintptr(0)) == 8
+	)
+	for _, l := range []layout{
+		{"i2c_smbus_ioctl_data", unsafe.Sizeof(smbus),
+			[]uintptr{unsafe.Offsetof(smbus.cmd), unsafe.Offsetof(smbus.size), unsafe.Offsetof(smbus.data)},
+			[2][]uintptr{{12, 1, 4, 8}, {16, 1, 4, 8}}},
+		{"i2c_msg", unsafe.Sizeof(msg),
+			[]uintptr{unsafe.Offsetof(msg.flags), unsafe.Offsetof(msg.len), unsafe.Offsetof(msg.buf)},
+			[2][]uintptr{{12, 2, 4, 8}, {16, 2, 4, 8}}},
+		{"i2c_rdwr_ioctl_data", unsafe.Sizeof(rdwr),
+			[]uintptr{unsafe.Offsetof(rdwr.nmsgs)},
+			[2][]uintptr{{8, 4}, {16, 8}}},
+		{"spi_ioc_transfer", unsafe.Sizeof(spi),
+			[]uintptr{unsafe.Offsetof(spi.Length), unsafe.Offsetof(spi.SpeedHz), unsafe.Offsetof(spi.DelayUsecs),
+				unsafe.Offsetof(spi.BitsPerWord), unsafe.Offsetof(spi.CsChange)},
+			[2][]uintptr{{32, 16, 20, 24, 26, 27}, {32, 16, 20, 24, 26, 27}}},
+		{"termios2", unsafe.Sizeof(term),
+			[]uintptr{unsafe.Offsetof(term.Line), unsafe.Offsetof(term.Cc), unsafe.Offsetof(term.Ispeed), unsafe.Offsetof(term.Ospeed)},
+			[2][]uintptr{{44, 16, 17, 36, 40}, {44, 16, 17, 36, 40}}},
+		{"serial_rs485", unsafe.Sizeof(rs485),
+			[]uintptr{unsafe.Offsetof(rs485.DelayRTSBeforeSend), unsafe.Offsetof(rs485.DelayRTSAfterSend)},
+			[2][]uintptr{{32, 4, 8}, {32, 4, 8}}},
+	} {
+		want := l.want[0]
+		if arch64 {
+			want = l.want[1]
+		}
+		got := append([]uintptr{l.size}, l.offsets...)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s layout mismatch: %v, expected %v", l.name, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestIoctlNumbers(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		request uintptr
+		want    uintptr
+	}{
+		{"TCGETS2", TCGETS2(), 0x802C542A},
+		{"TCSETS2", TCSETS2(), 0x402C542B},
+		{"SPI_IOC_RD_MODE", SPI_IOC_RD_MODE(), 0x80016B01},
+		{"SPI_IOC_WR_BITS_PER_WORD", SPI_IOC_WR_BITS_PER_WORD(), 0x40016B03},
+		{"SPI_IOC_RD_MAX_SPEED_HZ", SPI_IOC_RD_MAX_SPEED_HZ(), 0x80046B04},
+		{"SPI_IOC_WR_MODE32", SPI_IOC_WR_MODE32(), 0x40046B05},
+		{"SPI_IOC_MESSAGE(2)", SPI_IOC_MESSAGE(2), 0x40406B00},
+	} {
+		if c.request != c.want {
+			t.Errorf("%s mismatch: 0x%08X, expected 0x%08X", c.name, c.request, c.want)
+		}
+	}
+	if sys.IOC_SIZE(SPI_IOC_MESSAGE(3)) != 3*unsafe.Sizeof(spiIoctlTransfer{}) {
+		t.Errorf("SPI_IOC_MESSAGE size mismatch")
+	}
+}
@@ -0,0 +1,222 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"time"
+)
+
+const DEFAULT_TIMEOUT = time.Second
+
+// Master polls slaves on a serial line. Requests are serialized and spaced by
+// the inter-frame silence. Each request waits for its response until the
+// deadline of its context, or DEFAULT_TIMEOUT if the context has none.
+type Master struct {
+	sem     chan struct{} // held while a request owns the line
+	port    Port
+	silence time.Duration
+	idle    time.Time // end of the last frame on the line
+}
+
+// NewMaster returns a master on port, running at baud.
+func NewMaster(port Port, baud uint) *Master {
+	return &Master{
+		sem:     make(chan struct{}, 1),
+		port:    port,
+		silence: Silence(baud),
+	}
+}
+
+// flusher is implemented by ports able to discard stale input, like *bus.Serial.
+type flusher interface {
+	Flush() error
+}
+
+var aLongTimeAgo = time.Unix(1, 0)
+
+// do sends the request pdu to unit and returns the response pdu.
+func (this *Master) do(ctx context.Context, unit byte, pdu []byte) (resp []byte, err error) {
+	select {
+	case this.sem <- struct{}{}:
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	}
+	defer func() { <-this.sem }()
+	if d := time.Until(this.idle.Add(this.silence)); d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			err = ctx.Err()
+			return
+		}
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if f, ok := this.port.(flusher); ok {
+		f.Flush()
+	}
+	adu := appendCRC(append([]byte{unit}, pdu...))
+	_, err = this.port.Write(adu)
+	this.idle = time.Now()
+	if err != nil || unit == BROADCAST {
+		return
+	}
+	deadline, byCtx := ctx.Deadline()
+	if !byCtx {
+		deadline = time.Now().Add(DEFAULT_TIMEOUT)
+	}
+	if err = this.port.SetReadDeadline(deadline); err != nil {
+		return
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			this.port.SetReadDeadline(aLongTimeAgo)
+		case <-done:
+		}
+	}()
+	adu, err = readFrame(this.port, responseLength, 0)
+	close(done)
+	<-exited
+	this.idle = time.Now()
+	this.port.SetReadDeadline(time.Time{})
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else if byCtx && os.IsTimeout(err) {
+			err = context.DeadlineExceeded
+		}
+		return
+	}
+	if !checkCRC(adu) {
+		err = ErrCRC
+		return
+	}
+	if adu[0] != unit || adu[1]&0x7F != pdu[0] {
+		err = ErrInvalidResponse
+		return
+	}
+	if adu[1]&0x80 != 0 {
+		err = &Exception{pdu[0], adu[2]}
+		return
+	}
+	resp = adu[1 : len(adu)-2]
+	return
+}
+
+func (this *Master) read(ctx context.Context, unit, function byte, addr, quantity, max uint16) (p []byte, err error) {
+	if quantity == 0 || quantity > max {
+		err = ErrInvalidQuantity
+		return
+	}
+	pdu := make([]byte, 5)
+	pdu[0] = function
+	binary.BigEndian.PutUint16(pdu[1:], addr)
+	binary.BigEndian.PutUint16(pdu[3:], quantity)
+	resp, err := this.do(ctx, unit, pdu)
+	if err != nil || unit == BROADCAST {
+		return
+	}
+	size := int(quantity) * 2
+	if function == READ_COILS || function == READ_DISCRETE_INPUTS {
+		size = (int(quantity) + 7) / 8
+	}
+	if int(resp[1]) != size {
+		err = ErrInvalidResponse
+		return
+	}
+	p = resp[2:]
+	return
+}
+
+func (this *Master) readBits(ctx context.Context, unit, function byte, addr, quantity uint16) (bits []bool, err error) {
+	p, err := this.read(ctx, unit, function, addr, quantity, MAX_READ_BITS)
+	if err != nil || p == nil {
+		return
+	}
+	bits = unpackBits(p, int(quantity))
+	return
+}
+
+func (this *Master) readRegisters(ctx context.Context, unit, function byte, addr, quantity uint16) (regs []uint16, err error) {
+	p, err := this.read(ctx, unit, function, addr, quantity, MAX_READ_REGISTERS)
+	if err != nil || p == nil {
+		return
+	}
+	regs = unpackRegisters(p)
+	return
+}
+
+// ReadCoils reads quantity coils starting at addr.
+func (this *Master) ReadCoils(ctx context.Context, unit byte, addr, quantity uint16) ([]bool, error) {
+	return this.readBits(ctx, unit, READ_COILS, addr, quantity)
+}
+
+// ReadDiscreteInputs reads quantity discrete inputs starting at addr.
+func (this *Master) ReadDiscreteInputs(ctx context.Context, unit byte, addr, quantity uint16) ([]bool, error) {
+	return this.readBits(ctx, unit, READ_DISCRETE_INPUTS, addr, quantity)
+}
+
+// ReadHoldingRegisters reads quantity holding registers starting at addr.
+func (this *Master) ReadHoldingRegisters(ctx context.Context, unit byte, addr, quantity uint16) ([]uint16, error) {
+	return this.readRegisters(ctx, unit, READ_HOLDING_REGISTERS, addr, quantity)
+}
+
+// ReadInputRegisters reads quantity input registers starting at addr.
+func (this *Master) ReadInputRegisters(ctx context.Context, unit byte, addr, quantity uint16) ([]uint16, error) {
+	return this.readRegisters(ctx, unit, READ_INPUT_REGISTERS, addr, quantity)
+}
+
+// write sends pdu and checks that the slave echoes its first 5 bytes.
+func (this *Master) write(ctx context.Context, unit byte, pdu []byte) error {
+	resp, err := this.do(ctx, unit, pdu)
+	if err != nil || unit == BROADCAST {
+		return err
+	}
+	if len(resp) != 5 || string(resp) != string(pdu[:5]) {
+		return ErrInvalidResponse
+	}
+	return nil
+}
+
+// WriteSingleCoil sets the coil at addr.
+func (this *Master) WriteSingleCoil(ctx context.Context, unit byte, addr uint16, value bool) error {
+	pdu := []byte{WRITE_SINGLE_COIL, byte(addr >> 8), byte(addr), 0x00, 0x00}
+	if value {
+		pdu[3] = 0xFF
+	}
+	return this.write(ctx, unit, pdu)
+}
+
+// WriteSingleRegister sets the holding register at addr.
+func (this *Master) WriteSingleRegister(ctx context.Context, unit byte, addr, value uint16) error {
+	return this.write(ctx, unit, []byte{WRITE_SINGLE_REGISTER, byte(addr >> 8), byte(addr), byte(value >> 8), byte(value)})
+}
+
+// WriteMultipleCoils sets len(values) coils starting at addr.
+func (this *Master) WriteMultipleCoils(ctx context.Context, unit byte, addr uint16, values []bool) error {
+	if len(values) == 0 || len(values) > MAX_WRITE_BITS {
+		return ErrInvalidQuantity
+	}
+	p := packBits(values)
+	pdu := []byte{WRITE_MULTIPLE_COILS, byte(addr >> 8), byte(addr), byte(len(values) >> 8), byte(len(values)), byte(len(p))}
+	return this.write(ctx, unit, append(pdu, p...))
+}
+
+// WriteMultipleRegisters sets len(values) holding registers starting at addr.
+func (this *Master) WriteMultipleRegisters(ctx context.Context, unit byte, addr uint16, values []uint16) error {
+	if len(values) == 0 || len(values) > MAX_WRITE_REGISTERS {
+		return ErrInvalidQuantity
+	}
+	p := packRegisters(values)
+	pdu := []byte{WRITE_MULTIPLE_REGISTERS, byte(addr >> 8), byte(addr), byte(len(values) >> 8), byte(len(values)), byte(len(p))}
+	return this.write(ctx, unit, append(pdu, p...))
+}
@@ -0,0 +1,214 @@
+//go:build linux
+// +build linux
+
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zyxar/berry/bus"
+	"github.com/zyxar/berry/bus/bustest"
+)
+
+func TestCRC16(t *testing.T) {
+	// read holding registers 0x006B..0x006D of unit 0x11
+	adu := appendCRC([]byte{0x11, 0x03, 0x00, 0x6B, 0x00, 0x03})
+	if adu[6] != 0x76 || adu[7] != 0x87 {
+		t.Errorf("crc mismatch: %X", adu[6:])
+	}
+	if !checkCRC(adu) {
+		t.Error("crc check failed")
+	}
+	adu[3] ^= 1
+	if checkCRC(adu) {
+		t.Error("corrupted frame passed crc check")
+	}
+}
+
+func TestSilence(t *testing.T) {
+	if d := Silence(9600); d < 4000*time.Microsecond || d > 4020*time.Microsecond {
+		t.Errorf("silence mismatch at 9600: %v", d)
+	}
+	if d := Silence(115200); d != 1750*time.Microsecond {
+		t.Errorf("silence mismatch at 115200: %v", d)
+	}
+}
+
+// simulator is a minimal slave answering on the master side of a pty.
+type simulator struct {
+	sync.Mutex
+	port    *os.File
+	unit    byte
+	coils   []bool
+	regs    []uint16
+	corrupt bool
+}
+
+func (this *simulator) serve() {
+	for {
+		adu, err := readFrame(this.port, requestLength, 0)
+		if err != nil {
+			return
+		}
+		this.Lock()
+		if !checkCRC(adu) || adu[0] != this.unit {
+			this.Unlock()
+			continue
+		}
+		function := adu[1]
+		addr := int(binary.BigEndian.Uint16(adu[2:]))
+		value := binary.BigEndian.Uint16(adu[4:])
+		resp := []byte{adu[0], function}
+		switch function {
+		case READ_COILS:
+			if addr+int(value) > len(this.coils) {
+				resp = []byte{adu[0], function | 0x80, ILLEGAL_DATA_ADDRESS}
+				break
+			}
+			p := packBits(this.coils[addr : addr+int(value)])
+			resp = append(append(resp, byte(len(p))), p...)
+		case READ_HOLDING_REGISTERS:
+			if addr+int(value) > len(this.regs) {
+				resp = []byte{adu[0], function | 0x80, ILLEGAL_DATA_ADDRESS}
+				break
+			}
+			p := packRegisters(this.regs[addr : addr+int(value)])
+			resp = append(append(resp, byte(len(p))), p...)
+		case WRITE_SINGLE_COIL:
+			this.coils[addr] = value == 0xFF00
+			resp = adu[:6]
+		case WRITE_SINGLE_REGISTER:
+			this.regs[addr] = value
+			resp = adu[:6]
+		case WRITE_MULTIPLE_COILS:
+			copy(this.coils[addr:], unpackBits(adu[7:7+int(adu[6])], int(value)))
+			resp = adu[:6]
+		case WRITE_MULTIPLE_REGISTERS:
+			copy(this.regs[addr:], unpackRegisters(adu[7:7+int(adu[6])]))
+			resp = adu[:6]
+		default:
+			resp = []byte{adu[0], function | 0x80, ILLEGAL_FUNCTION}
+		}
+		resp = appendCRC(append([]byte(nil), resp...))
+		if this.corrupt {
+			resp[len(resp)-1] ^= 0xFF
+		}
+		this.Unlock()
+		this.port.Write(resp)
+	}
+}
+
+func TestMaster(t *testing.T) {
+	pty, path, err := bustest.OpenPty()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pty.Close()
+	port, err := bus.OpenSerialWithOptions(path, &bus.SerialOptions{Baud: 19200, KeepModemLines: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+	sim := &simulator{
+		port:  pty,
+		unit:  0x11,
+		coils: make([]bool, 16),
+		regs:  []uint16{0x1234, 0x5678, 0x9ABC, 0, 0, 0, 0, 0},
+	}
+	go sim.serve()
+	m := NewMaster(port, 19200)
+	ctx := context.Background()
+
+	regs, err := m.ReadHoldingRegisters(ctx, 0x11, 1, 2)
+	if err != nil || len(regs) != 2 || regs[0] != 0x5678 || regs[1] != 0x9ABC {
+		t.Errorf("read holding registers mismatch: %X, %v", regs, err)
+	}
+	if err = m.WriteSingleRegister(ctx, 0x11, 3, 0xBEEF); err != nil {
+		t.Errorf("write single register failed: %v", err)
+	}
+	if err = m.WriteMultipleRegisters(ctx, 0x11, 4, []uint16{1, 2, 3}); err != nil {
+		t.Errorf("write multiple registers failed: %v", err)
+	}
+	regs, err = m.ReadHoldingRegisters(ctx, 0x11, 3, 4)
+	if err != nil || len(regs) != 4 || regs[0] != 0xBEEF || regs[3] != 3 {
+		t.Errorf("read back registers mismatch: %X, %v", regs, err)
+	}
+	if err = m.WriteSingleCoil(ctx, 0x11, 9, true); err != nil {
+		t.Errorf("write single coil failed: %v", err)
+	}
+	if err = m.WriteMultipleCoils(ctx, 0x11, 0, []bool{true, false, true}); err != nil {
+		t.Errorf("write multiple coils failed: %v", err)
+	}
+	coils, err := m.ReadCoils(ctx, 0x11, 0, 10)
+	if err != nil || len(coils) != 10 || !coils[0] || coils[1] || !coils[2] || !coils[9] {
+		t.Errorf("read coils mismatch: %v, %v", coils, err)
+	}
+
+	_, err = m.ReadHoldingRegisters(ctx, 0x11, 6, 4)
+	if e, ok := err.(*Exception); !ok || e.Code != ILLEGAL_DATA_ADDRESS || e.Function != READ_HOLDING_REGISTERS {
+		t.Errorf("exception expected: %v", err)
+	}
+	if _, err = m.ReadInputRegisters(ctx, 0x11, 0, 1); err == nil {
+		t.Error("exception expected")
+	}
+
+	start := time.Now()
+	short, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	if _, err = m.ReadHoldingRegisters(short, 0x12, 0, 1); err != context.DeadlineExceeded {
+		t.Errorf("deadline expected: %v", err)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond || d >= DEFAULT_TIMEOUT {
+		t.Errorf("deadline not honoured: %v", d)
+	}
+	start = time.Now()
+	if _, err = m.ReadHoldingRegisters(ctx, 0x12, 0, 1); !os.IsTimeout(err) {
+		t.Errorf("timeout expected: %v", err)
+	}
+	if d := time.Since(start); d < DEFAULT_TIMEOUT {
+		t.Errorf("timeout too short: %v", d)
+	}
+
+	// a slow request does not hold up a caller with a shorter deadline
+	pending := make(chan error)
+	go func() {
+		_, err := m.ReadHoldingRegisters(ctx, 0x12, 0, 1)
+		pending <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	start = time.Now()
+	short, cancel = context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err = m.ReadHoldingRegisters(short, 0x11, 0, 1); err != context.DeadlineExceeded {
+		t.Errorf("deadline expected while line busy: %v", err)
+	}
+	if d := time.Since(start); d >= DEFAULT_TIMEOUT/2 {
+		t.Errorf("deadline not honoured while line busy: %v", d)
+	}
+	<-pending
+	canceled, cancel := context.WithCancel(ctx)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err = m.ReadHoldingRegisters(canceled, 0x12, 0, 1); err != context.Canceled {
+		t.Errorf("cancellation expected: %v", err)
+	}
+
+	if err = m.WriteSingleRegister(ctx, BROADCAST, 0, 0xFFFF); err != nil {
+		t.Errorf("broadcast failed: %v", err)
+	}
+
+	sim.Lock()
+	sim.corrupt = true
+	sim.Unlock()
+	if _, err = m.ReadHoldingRegisters(ctx, 0x11, 0, 1); err != ErrCRC {
+		t.Errorf("crc error expected: %v", err)
+	}
+
+	if _, err = m.ReadHoldingRegisters(ctx, 0x11, 0, 0); err != ErrInvalidQuantity {
+		t.Errorf("invalid quantity accepted: %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+// Package modbus implements Modbus RTU over serial lines.
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const ( // function codes
+	READ_COILS               = 0x01
+	READ_DISCRETE_INPUTS     = 0x02
+	READ_HOLDING_REGISTERS   = 0x03
+	READ_INPUT_REGISTERS     = 0x04
+	WRITE_SINGLE_COIL        = 0x05
+	WRITE_SINGLE_REGISTER    = 0x06
+	WRITE_MULTIPLE_COILS     = 0x0F
+	WRITE_MULTIPLE_REGISTERS = 0x10
+)
+
+const ( // exception codes
+	ILLEGAL_FUNCTION                        = 0x01
+	ILLEGAL_DATA_ADDRESS                    = 0x02
+	ILLEGAL_DATA_VALUE                      = 0x03
+	SERVER_DEVICE_FAILURE                   = 0x04
+	ACKNOWLEDGE                             = 0x05
+	SERVER_DEVICE_BUSY                      = 0x06
+	MEMORY_PARITY_ERROR                     = 0x08
+	GATEWAY_PATH_UNAVAILABLE                = 0x0A
+	GATEWAY_TARGET_DEVICE_FAILED_TO_RESPOND = 0x0B
+)
+
+const (
+	BROADCAST = 0 // unit id addressing all slaves, which do not answer
+
+	MAX_ADU_SIZE        = 256
+	MAX_READ_BITS       = 2000
+	MAX_READ_REGISTERS  = 125
+	MAX_WRITE_BITS      = 1968
+	MAX_WRITE_REGISTERS = 123
+)
+
+var (
+	ErrCRC             = errors.New("modbus: crc mismatch")
+	ErrInvalidResponse = errors.New("modbus: invalid response")
+	ErrInvalidQuantity = errors.New("modbus: invalid quantity")
+)
+
+// Exception is a Modbus exception response.
+type Exception struct {
+	Function byte
+	Code     byte
+}
+
+func (this *Exception) Error() string {
+	var s string
+	switch this.Code {
+	case ILLEGAL_FUNCTION:
+		s = "illegal function"
+	case ILLEGAL_DATA_ADDRESS:
+		s = "illegal data address"
+	case ILLEGAL_DATA_VALUE:
+		s = "illegal data value"
+	case SERVER_DEVICE_FAILURE:
+		s = "server device failure"
+	case ACKNOWLEDGE:
+		s = "acknowledge"
+	case SERVER_DEVICE_BUSY:
+		s = "server device busy"
+	case MEMORY_PARITY_ERROR:
+		s = "memory parity error"
+	case GATEWAY_PATH_UNAVAILABLE:
+		s = "gateway path unavailable"
+	case GATEWAY_TARGET_DEVICE_FAILED_TO_RESPOND:
+		s = "gateway target device failed to respond"
+	default:
+		s = fmt.Sprintf("exception 0x%02x", this.Code)
+	}
+	return fmt.Sprintf("modbus: function 0x%02x: %s", this.Function, s)
+}
+
+// Port is the serial line Modbus talks over, such as *bus.Serial.
+type Port interface {
+	io.ReadWriter
+	SetReadDeadline(t time.Time) error
+}
+
+// CRC16 computes the Modbus CRC of p.
+func CRC16(p []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range p {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// appendCRC appends the CRC of adu, low byte first.
+func appendCRC(adu []byte) []byte {
+	crc := CRC16(adu)
+	return append(adu, byte(crc), byte(crc>>8))
+}
+
+func checkCRC(adu []byte) bool {
+	n := len(adu) - 2
+	return n >= 0 && CRC16(adu[:n]) == binary.LittleEndian.Uint16(adu[n:])
+}
+
+// Silence returns the minimum idle time between frames at baud (3.5
+// characters of 11 bits, but at least 1.75ms).
+func Silence(baud uint) time.Duration {
+	if baud == 0 || baud > 19200 {
+		return 1750 * time.Microsecond
+	}
+	return time.Duration(385) * time.Second / time.Duration(10*baud)
+}
+
+func packBits(bits []bool) []byte {
+	p := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			p[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return p
+}
+
+func unpackBits(p []byte, n int) []bool {
+	bits := make([]bool, n)
+	for i := range bits {
+		bits[i] = p[i/8]&(1<<uint(i%8)) != 0
+	}
+	return bits
+}
+
+func packRegisters(regs []uint16) []byte {
+	p := make([]byte, 2*len(regs))
+	for i, r := range regs {
+		binary.BigEndian.PutUint16(p[2*i:], r)
+	}
+	return p
+}
+
+func unpackRegisters(p []byte) []uint16 {
+	regs := make([]uint16, len(p)/2)
+	for i := range regs {
+		regs[i] = binary.BigEndian.Uint16(p[2*i:])
+	}
+	return regs
+}
+
+// requestLength returns the length of a request ADU from its first bytes,
+// or 0 if more bytes are needed to tell.
+func requestLength(adu []byte) int {
+	if len(adu) < 2 {
+		return 0
+	}
+	switch adu[1] {
+	case WRITE_MULTIPLE_COILS, WRITE_MULTIPLE_REGISTERS:
+		if len(adu) < 7 {
+			return 0
+		}
+		return 9 + int(adu[6])
+	default:
+		return 8
+	}
+}
+
+// responseLength returns the length of a response ADU from its first bytes,
+// or 0 if more bytes are needed to tell.
+func responseLength(adu []byte) int {
+	if len(adu) < 2 {
+		return 0
+	}
+	if adu[1]&0x80 != 0 {
+		return 5
+	}
+	switch adu[1] {
+	case READ_COILS, READ_DISCRETE_INPUTS, READ_HOLDING_REGISTERS, READ_INPUT_REGISTERS:
+		if len(adu) < 3 {
+			return 0
+		}
+		return 5 + int(adu[2])
+	default:
+		return 8
+	}
+}
+
+// readFrame reads a frame from port until length reports it complete. A
+// non-zero gap bounds the silence between bytes once the frame has started.
+func readFrame(port Port, length func([]byte) int, gap time.Duration) (adu []byte, err error) {
+	adu = make([]byte, 0, MAX_ADU_SIZE)
+	need := 2
+	for len(adu) < need {
+		if gap > 0 && len(adu) > 0 {
+			if err = port.SetReadDeadline(time.Now().Add(gap)); err != nil {
+				return
+			}
+		}
+		var n int
+		n, err = port.Read(adu[len(adu):need])
+		adu = adu[:len(adu)+n]
+		if err != nil {
+			return
+		}
+		if l := length(adu); l > 0 {
+			if l > MAX_ADU_SIZE {
+				err = ErrInvalidResponse
+				return
+			}
+			need = l
+		} else if len(adu) == need {
+			need++
+		}
+	}
+	return
+}
@@ -0,0 +1,303 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+	"time"
+)
+
+// Coil maps a coil or discrete input to functions. A nil Set makes it read-only.
+type Coil struct {
+	Get func() (bool, error)
+	Set func(bool) error
+}
+
+// Register maps a holding or input register to functions. A nil Set makes it
+// read-only.
+type Register struct {
+	Get func() (uint16, error)
+	Set func(uint16) error
+}
+
+// Pin is a digital line, such as core.Pin.
+type Pin interface {
+	DigitalWrite(v uint8) error
+	DigitalRead() uint8
+}
+
+// PinCoil maps a coil to pin, which should be configured as an output.
+func PinCoil(pin Pin) Coil {
+	return Coil{
+		Get: func() (bool, error) { return pin.DigitalRead() != 0, nil },
+		Set: func(v bool) error {
+			if v {
+				return pin.DigitalWrite(1)
+			}
+			return pin.DigitalWrite(0)
+		},
+	}
+}
+
+// PinInput maps a discrete input to pin.
+func PinInput(pin Pin) Coil {
+	return Coil{Get: func() (bool, error) { return pin.DigitalRead() != 0, nil }}
+}
+
+// Slave answers requests addressed to its unit id on a serial line, and
+// carries out broadcast writes silently.
+type Slave struct {
+	// FrameTimeout bounds the gap between bytes of a request. USB adapters
+	// deliver bytes in bursts, so it is looser than the 1.5 characters of
+	// the specification.
+	FrameTimeout time.Duration
+
+	m        sync.Mutex
+	port     Port
+	unit     byte
+	silence  time.Duration
+	coils    map[uint16]Coil
+	inputs   map[uint16]Coil
+	holdings map[uint16]Register
+	iregs    map[uint16]Register
+}
+
+// NewSlave returns a slave with id unit on port, running at baud.
+func NewSlave(port Port, baud uint, unit byte) *Slave {
+	return &Slave{
+		FrameTimeout: 50 * time.Millisecond,
+		port:         port,
+		unit:         unit,
+		silence:      Silence(baud),
+		coils:        make(map[uint16]Coil),
+		inputs:       make(map[uint16]Coil),
+		holdings:     make(map[uint16]Register),
+		iregs:        make(map[uint16]Register),
+	}
+}
+
+// Unit returns the unit id of the slave.
+func (this *Slave) Unit() byte {
+	return this.unit
+}
+
+// HandleCoil maps the coil at addr.
+func (this *Slave) HandleCoil(addr uint16, c Coil) {
+	this.m.Lock()
+	this.coils[addr] = c
+	this.m.Unlock()
+}
+
+// HandleDiscreteInput maps the discrete input at addr; c.Set is ignored.
+func (this *Slave) HandleDiscreteInput(addr uint16, c Coil) {
+	this.m.Lock()
+	this.inputs[addr] = c
+	this.m.Unlock()
+}
+
+// HandleHoldingRegister maps the holding register at addr.
+func (this *Slave) HandleHoldingRegister(addr uint16, r Register) {
+	this.m.Lock()
+	this.holdings[addr] = r
+	this.m.Unlock()
+}
+
+// HandleInputRegister maps the input register at addr; r.Set is ignored.
+func (this *Slave) HandleInputRegister(addr uint16, r Register) {
+	this.m.Lock()
+	this.iregs[addr] = r
+	this.m.Unlock()
+}
+
+// Serve answers requests until reading from the port fails for a reason
+// other than a timeout, e.g. because it was closed.
+func (this *Slave) Serve() error {
+	for {
+		this.port.SetReadDeadline(time.Time{})
+		adu, err := readFrame(this.port, requestLength, this.FrameTimeout)
+		if err != nil && !os.IsTimeout(err) && err != ErrInvalidResponse {
+			return err
+		}
+		if err != nil || !checkCRC(adu) {
+			if err = this.resync(); err != nil {
+				return err
+			}
+			continue
+		}
+		unit := adu[0]
+		if unit != this.unit && unit != BROADCAST {
+			continue
+		}
+		resp := this.handle(adu[1:len(adu)-2], unit == BROADCAST)
+		if unit == BROADCAST {
+			continue
+		}
+		time.Sleep(this.silence)
+		if _, err = this.port.Write(appendCRC(append([]byte{unit}, resp...))); err != nil {
+			return err
+		}
+	}
+}
+
+// resync discards input until the line has been idle for the inter-frame
+// silence, so that the next read starts on a frame boundary.
+func (this *Slave) resync() error {
+	p := make([]byte, MAX_ADU_SIZE)
+	for {
+		if err := this.port.SetReadDeadline(time.Now().Add(this.silence)); err != nil {
+			return err
+		}
+		if _, err := this.port.Read(p); err != nil {
+			if os.IsTimeout(err) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// handle executes the request pdu and returns the response pdu. Reads are
+// skipped for broadcasts, which get no response.
+func (this *Slave) handle(pdu []byte, broadcast bool) []byte {
+	defer this.m.Unlock()
+	this.m.Lock()
+	function := pdu[0]
+	addr := binary.BigEndian.Uint16(pdu[1:])
+	value := binary.BigEndian.Uint16(pdu[3:])
+	var code byte
+	var data []byte
+	switch function {
+	case READ_COILS, READ_DISCRETE_INPUTS:
+		if broadcast {
+			return nil
+		}
+		table := this.coils
+		if function == READ_DISCRETE_INPUTS {
+			table = this.inputs
+		}
+		data, code = readBits(table, addr, value)
+	case READ_HOLDING_REGISTERS, READ_INPUT_REGISTERS:
+		if broadcast {
+			return nil
+		}
+		table := this.holdings
+		if function == READ_INPUT_REGISTERS {
+			table = this.iregs
+		}
+		data, code = readRegisters(table, addr, value)
+	case WRITE_SINGLE_COIL:
+		if value != 0xFF00 && value != 0x0000 {
+			code = ILLEGAL_DATA_VALUE
+			break
+		}
+		code = writeBits(this.coils, addr, []bool{value == 0xFF00})
+	case WRITE_SINGLE_REGISTER:
+		code = writeRegisters(this.holdings, addr, []uint16{value})
+	case WRITE_MULTIPLE_COILS:
+		if value == 0 || value > MAX_WRITE_BITS || int(pdu[5]) != (int(value)+7)/8 {
+			code = ILLEGAL_DATA_VALUE
+			break
+		}
+		code = writeBits(this.coils, addr, unpackBits(pdu[6:], int(value)))
+	case WRITE_MULTIPLE_REGISTERS:
+		if value == 0 || value > MAX_WRITE_REGISTERS || int(pdu[5]) != 2*int(value) {
+			code = ILLEGAL_DATA_VALUE
+			break
+		}
+		code = writeRegisters(this.holdings, addr, unpackRegisters(pdu[6:]))
+	default:
+		code = ILLEGAL_FUNCTION
+	}
+	if code != 0 {
+		return []byte{function | 0x80, code}
+	}
+	if data != nil {
+		return append([]byte{function, byte(len(data))}, data...)
+	}
+	return pdu[:5]
+}
+
+// span reports whether quantity items from addr stay within the address space.
+func span(addr, quantity, max uint16) bool {
+	return quantity > 0 && quantity <= max && int(addr)+int(quantity) <= 0x10000
+}
+
+func readBits(table map[uint16]Coil, addr, quantity uint16) (p []byte, code byte) {
+	if !span(addr, quantity, MAX_READ_BITS) {
+		code = ILLEGAL_DATA_VALUE
+		return
+	}
+	bits := make([]bool, quantity)
+	for i := range bits {
+		c, ok := table[addr+uint16(i)]
+		if !ok || c.Get == nil {
+			code = ILLEGAL_DATA_ADDRESS
+			return
+		}
+		var err error
+		if bits[i], err = c.Get(); err != nil {
+			code = SERVER_DEVICE_FAILURE
+			return
+		}
+	}
+	p = packBits(bits)
+	return
+}
+
+func readRegisters(table map[uint16]Register, addr, quantity uint16) (p []byte, code byte) {
+	if !span(addr, quantity, MAX_READ_REGISTERS) {
+		code = ILLEGAL_DATA_VALUE
+		return
+	}
+	regs := make([]uint16, quantity)
+	for i := range regs {
+		r, ok := table[addr+uint16(i)]
+		if !ok || r.Get == nil {
+			code = ILLEGAL_DATA_ADDRESS
+			return
+		}
+		var err error
+		if regs[i], err = r.Get(); err != nil {
+			code = SERVER_DEVICE_FAILURE
+			return
+		}
+	}
+	p = packRegisters(regs)
+	return
+}
+
+// writeBits checks that every coil is writable before setting any.
+func writeBits(table map[uint16]Coil, addr uint16, values []bool) byte {
+	if !span(addr, uint16(len(values)), MAX_WRITE_BITS) {
+		return ILLEGAL_DATA_ADDRESS
+	}
+	for i := range values {
+		if c, ok := table[addr+uint16(i)]; !ok || c.Set == nil {
+			return ILLEGAL_DATA_ADDRESS
+		}
+	}
+	for i, v := range values {
+		if err := table[addr+uint16(i)].Set(v); err != nil {
+			return SERVER_DEVICE_FAILURE
+		}
+	}
+	return 0
+}
+
+// writeRegisters checks that every register is writable before setting any.
+func writeRegisters(table map[uint16]Register, addr uint16, values []uint16) byte {
+	if !span(addr, uint16(len(values)), MAX_WRITE_REGISTERS) {
+		return ILLEGAL_DATA_ADDRESS
+	}
+	for i := range values {
+		if r, ok := table[addr+uint16(i)]; !ok || r.Set == nil {
+			return ILLEGAL_DATA_ADDRESS
+		}
+	}
+	for i, v := range values {
+		if err := table[addr+uint16(i)].Set(v); err != nil {
+			return SERVER_DEVICE_FAILURE
+		}
+	}
+	return 0
+}
@@ -0,0 +1,122 @@
+//go:build linux
+// +build linux
+
+package modbus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zyxar/berry/bus"
+	"github.com/zyxar/berry/bus/bustest"
+)
+
+type fakePin struct {
+	sync.Mutex
+	v uint8
+}
+
+func (this *fakePin) DigitalWrite(v uint8) error {
+	this.Lock()
+	this.v = v
+	this.Unlock()
+	return nil
+}
+
+func (this *fakePin) DigitalRead() uint8 {
+	this.Lock()
+	defer this.Unlock()
+	return this.v
+}
+
+func TestSlave(t *testing.T) {
+	pty, path, err := bustest.OpenPty()
+	if err != nil {
+		t.Skip(err)
+	}
+	port, err := bus.OpenSerialWithOptions(path, &bus.SerialOptions{Baud: 19200, KeepModemLines: true})
+	if err != nil {
+		pty.Close()
+		t.Fatal(err)
+	}
+	defer port.Close()
+
+	var pins [4]fakePin
+	var setpoint uint16 = 0x0100
+	s := NewSlave(port, 19200, 0x22)
+	for i := range pins {
+		s.HandleCoil(uint16(i), PinCoil(&pins[i]))
+	}
+	s.HandleDiscreteInput(0, PinInput(&pins[0]))
+	s.HandleHoldingRegister(10, Register{
+		Get: func() (uint16, error) { return setpoint, nil },
+		Set: func(v uint16) error { setpoint = v; return nil },
+	})
+	s.HandleHoldingRegister(11, Register{Get: func() (uint16, error) { return 0xCAFE, nil }})
+	s.HandleInputRegister(0, Register{Get: func() (uint16, error) { return 0, errors.New("sensor offline") }})
+	done := make(chan error)
+	go func() { done <- s.Serve() }()
+	defer func() {
+		pty.Close()
+		<-done
+	}()
+
+	m := NewMaster(pty, 19200)
+	ctx := context.Background()
+	if err = m.WriteMultipleCoils(ctx, 0x22, 0, []bool{true, false, true, true}); err != nil {
+		t.Errorf("write multiple coils failed: %v", err)
+	}
+	if pins[0].DigitalRead() != 1 || pins[1].DigitalRead() != 0 || pins[3].DigitalRead() != 1 {
+		t.Error("coils not written to pins")
+	}
+	if err = m.WriteSingleCoil(ctx, 0x22, 2, false); err != nil {
+		t.Errorf("write single coil failed: %v", err)
+	}
+	coils, err := m.ReadCoils(ctx, 0x22, 0, 4)
+	if err != nil || len(coils) != 4 || !coils[0] || coils[1] || coils[2] || !coils[3] {
+		t.Errorf("read coils mismatch: %v, %v", coils, err)
+	}
+	inputs, err := m.ReadDiscreteInputs(ctx, 0x22, 0, 1)
+	if err != nil || len(inputs) != 1 || !inputs[0] {
+		t.Errorf("read discrete inputs mismatch: %v, %v", inputs, err)
+	}
+
+	if err = m.WriteSingleRegister(ctx, 0x22, 10, 0x0200); err != nil {
+		t.Errorf("write single register failed: %v", err)
+	}
+	regs, err := m.ReadHoldingRegisters(ctx, 0x22, 10, 2)
+	if err != nil || len(regs) != 2 || regs[0] != 0x0200 || regs[1] != 0xCAFE {
+		t.Errorf("read holding registers mismatch: %X, %v", regs, err)
+	}
+
+	err = m.WriteMultipleRegisters(ctx, 0x22, 10, []uint16{1, 2})
+	if e, ok := err.(*Exception); !ok || e.Code != ILLEGAL_DATA_ADDRESS {
+		t.Errorf("read-only register written: %v", err)
+	}
+	_, err = m.ReadHoldingRegisters(ctx, 0x22, 12, 1)
+	if e, ok := err.(*Exception); !ok || e.Code != ILLEGAL_DATA_ADDRESS {
+		t.Errorf("unmapped register read: %v", err)
+	}
+	_, err = m.ReadInputRegisters(ctx, 0x22, 0, 1)
+	if e, ok := err.(*Exception); !ok || e.Code != SERVER_DEVICE_FAILURE {
+		t.Errorf("failing getter not reported: %v", err)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	if _, err = m.ReadCoils(short, 0x23, 0, 1); err != context.DeadlineExceeded {
+		t.Errorf("other unit answered: %v", err)
+	}
+	if err = m.WriteSingleCoil(ctx, BROADCAST, 1, true); err != nil {
+		t.Errorf("broadcast failed: %v", err)
+	}
+	pty.Write([]byte{0x22, 0x03, 0xFF}) // line noise
+	time.Sleep(2 * s.FrameTimeout)
+	coils, err = m.ReadCoils(ctx, 0x22, 1, 1)
+	if err != nil || len(coils) != 1 || !coils[0] {
+		t.Errorf("broadcast not applied: %v, %v", coils, err)
+	}
+}
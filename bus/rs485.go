@@ -0,0 +1,154 @@
+//go:build linux
+// +build linux
+
+package bus
+
+import "time"
+
+const TIOCSER_TEMT = 0x01 /* transmitter physically empty */
+
+const lsrPollTimeout = 100 * time.Millisecond
+
+const (
+	SER_RS485_ENABLED        = 1 << 0
+	SER_RS485_RTS_ON_SEND    = 1 << 1
+	SER_RS485_RTS_AFTER_SEND = 1 << 2
+	SER_RS485_RX_DURING_TX   = 1 << 4
+	SER_RS485_TERMINATE_BUS  = 1 << 5
+) // from <linux/serial.h>
+
+// serialRS485 is struct serial_rs485 from <linux/serial.h>.
+type serialRS485 struct {
+	Flags              uint32
+	DelayRTSBeforeSend uint32 // ms
+	DelayRTSAfterSend  uint32 // ms
+	_                  [5]uint32
+}
+
+// RS485Options configures half-duplex RS-485 operation.
+type RS485Options struct {
+	RTSOnSend       bool          // level of RTS (or the driver enable pin) while sending
+	RTSAfterSend    bool          // level of RTS (or the driver enable pin) after sending
+	DelayBeforeSend time.Duration // from enabling the driver to the first bit, ms resolution
+	DelayAfterSend  time.Duration // from the last bit to disabling the driver, ms resolution
+	RxDuringTx      bool          // keep receiving while sending, e.g. to read back the echo
+	TerminateBus    bool          // enable the bus termination, if the hardware has one
+}
+
+// SetRS485 enables the kernel RS-485 mode of the port, where the driver
+// toggles RTS around each transmission. A nil opts disables it.
+func (this *Serial) SetRS485(opts *RS485Options) error {
+	conf := serialRS485{}
+	if opts != nil {
+		conf.Flags = SER_RS485_ENABLED
+		if opts.RTSOnSend {
+			conf.Flags |= SER_RS485_RTS_ON_SEND
+		}
+		if opts.RTSAfterSend {
+			conf.Flags |= SER_RS485_RTS_AFTER_SEND
+		}
+		if opts.RxDuringTx {
+			conf.Flags |= SER_RS485_RX_DURING_TX
+		}
+		if opts.TerminateBus {
+			conf.Flags |= SER_RS485_TERMINATE_BUS
+		}
+		conf.DelayRTSBeforeSend = uint32(opts.DelayBeforeSend / time.Millisecond)
+		conf.DelayRTSAfterSend = uint32(opts.DelayAfterSend / time.Millisecond)
+	}
+	return ioctlWrite(this, TIOCSRS485, conf)
+}
+
+// GetRS485 returns the kernel RS-485 configuration of the port, nil if disabled.
+func (this *Serial) GetRS485() (opts *RS485Options, err error) {
+	conf, err := ioctlRead[serialRS485](this, TIOCGRS485)
+	if err != nil {
+		return
+	}
+	if conf.Flags&SER_RS485_ENABLED == 0 {
+		return
+	}
+	opts = &RS485Options{
+		RTSOnSend:       conf.Flags&SER_RS485_RTS_ON_SEND != 0,
+		RTSAfterSend:    conf.Flags&SER_RS485_RTS_AFTER_SEND != 0,
+		DelayBeforeSend: time.Duration(conf.DelayRTSBeforeSend) * time.Millisecond,
+		DelayAfterSend:  time.Duration(conf.DelayRTSAfterSend) * time.Millisecond,
+		RxDuringTx:      conf.Flags&SER_RS485_RX_DURING_TX != 0,
+		TerminateBus:    conf.Flags&SER_RS485_TERMINATE_BUS != 0,
+	}
+	return
+}
+
+// Pin is a digital output driving a transceiver, such as core.Pin.
+type Pin interface {
+	DigitalWrite(v uint8) error
+}
+
+// RS485 is a serial port whose transceiver is enabled in software, for UARTs
+// without kernel RS-485 support: each Write raises the driver enable pin and
+// releases it once the transmitter is empty.
+type RS485 struct {
+	*Serial
+	de   Pin
+	opts RS485Options
+}
+
+// NewRS485 drives the transceiver of s with de. TerminateBus and RxDuringTx
+// are ignored: whether the echo of a write is received depends on the wiring
+// of the receiver enable, and input is never discarded, as it may hold the
+// start of a fast reply.
+func NewRS485(s *Serial, de Pin, opts *RS485Options) (*RS485, error) {
+	if opts == nil {
+		opts = &RS485Options{RTSOnSend: true}
+	}
+	r := &RS485{s, de, *opts}
+	if err := de.DigitalWrite(level(opts.RTSAfterSend)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func level(high bool) uint8 {
+	if high {
+		return 1
+	}
+	return 0
+}
+
+func (this *RS485) Write(p []byte) (n int, err error) {
+	if err = this.de.DigitalWrite(level(this.opts.RTSOnSend)); err != nil {
+		return
+	}
+	defer func() {
+		if e := this.de.DigitalWrite(level(this.opts.RTSAfterSend)); err == nil {
+			err = e
+		}
+	}()
+	time.Sleep(this.opts.DelayBeforeSend)
+	if n, err = this.Serial.Write(p); err != nil {
+		return
+	}
+	if err = this.waitSent(); err != nil {
+		return
+	}
+	time.Sleep(this.opts.DelayAfterSend)
+	return
+}
+
+// waitSent waits until the output queue and the transmitter shift register
+// are empty; ports not reporting their line status are trusted after Drain.
+func (this *RS485) waitSent() (err error) {
+	if err = this.Drain(); err != nil {
+		return
+	}
+	deadline := time.Now().Add(lsrPollTimeout)
+	for {
+		if lsr, e := ioctlRead[int32](this.Serial, TIOCSERGETLSR); e != nil || lsr&TIOCSER_TEMT != 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(50 * time.Microsecond)
+	}
+}
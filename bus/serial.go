@@ -1,34 +1,56 @@
-//+build linux,arm
+//go:build linux
+// +build linux
 
 package bus
 
 import (
-	"errors"
+	"context"
 	"os"
-	"runtime"
+	"sync"
 	"syscall"
-	"unsafe"
+	"time"
 
 	"github.com/zyxar/berry/sys"
 )
 
-var (
-	errInvalidBaud = errors.New("invalid baud")
+const ( // modem lines
+	MODEM_DTR = syscall.TIOCM_DTR
+	MODEM_RTS = syscall.TIOCM_RTS
+	MODEM_CTS = syscall.TIOCM_CTS
+	MODEM_DSR = syscall.TIOCM_DSR
+	MODEM_DCD = syscall.TIOCM_CD
+	MODEM_RI  = syscall.TIOCM_RI
 )
 
-type serial struct {
-	fd uintptr
+var aLongTimeAgo = time.Unix(1, 0)
+
+// Serial is a serial port. Reads and writes go through the runtime poller, so
+// they block only the calling goroutine and honour deadlines. Close may be
+// called from another goroutine to unblock them; they then fail with
+// os.ErrClosed.
+type Serial struct {
+	file     *os.File
+	rc       syscall.RawConn
+	timeout  time.Duration
+	m        sync.Mutex
+	deadline time.Time // guarded by m
 }
 
-func OpenSerial(device string, baud uint) (s *serial, err error) {
-	myBaud := getBaud(baud)
-	if myBaud == 0 {
-		err = errInvalidBaud
-		return
+// OpenSerial opens device at baud, 8N1 without flow control. Read waits up to
+// 10s for data.
+func OpenSerial(device string, baud uint) (s *Serial, err error) {
+	return OpenSerialWithOptions(device, &SerialOptions{Baud: baud, ReadTimeout: 10 * time.Second})
+}
+
+// OpenSerialWithOptions opens device in raw mode, configured by opts. nil
+// opts open it at 9600 baud, 8N1 without flow control.
+func OpenSerialWithOptions(device string, opts *SerialOptions) (s *Serial, err error) {
+	if opts == nil {
+		opts = &SerialOptions{Baud: 9600}
 	}
 	fd, err := syscall.Open(
 		device,
-		os.O_RDWR|syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NDELAY|syscall.O_NONBLOCK,
+		os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC,
 		0666)
 	if err != nil {
 		return
@@ -38,100 +60,213 @@ func OpenSerial(device string, baud uint) (s *serial, err error) {
 			syscall.Close(fd)
 		}
 	}()
-	term := syscall.Termios{}
-	if err = sys.Ioctl(uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&term))); err != nil {
+	term, err := sys.IoctlRead[termios2](uintptr(fd), TCGETS2())
+	if err != nil {
 		return
 	}
-	term.Ispeed = myBaud
-	term.Ospeed = myBaud
-	term.Cflag |= (syscall.CLOCAL | syscall.CREAD)
-	term.Cflag = uint32(int32(term.Cflag) & ^syscall.PARENB & ^syscall.CSTOPB & ^syscall.CSIZE)
-	term.Cflag |= syscall.CS8
-	term.Lflag = uint32(int32(term.Lflag) & ^(syscall.ICANON | syscall.ECHO | syscall.ECHOE | syscall.ISIG))
-	term.Oflag = uint32(int32(term.Oflag) & ^syscall.OPOST)
-	term.Cc[syscall.VMIN] = 0
-	term.Cc[syscall.VTIME] = 100
-	if err = sys.Ioctl(uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(&term))); err != nil {
+	if err = opts.apply(&term); err != nil {
 		return
 	}
-	status := 0
-	if err = sys.Ioctl(uintptr(fd), syscall.TIOCMGET, uintptr(unsafe.Pointer(&status))); err != nil {
+	if err = sys.IoctlWrite(uintptr(fd), TCSETS2(), term); err != nil {
 		return
 	}
-	status |= syscall.TIOCM_DTR | syscall.TIOCM_RTS
-	if err = sys.Ioctl(uintptr(fd), syscall.TIOCMSET, uintptr(unsafe.Pointer(&status))); err != nil {
+	if !opts.KeepModemLines {
+		status := int32(syscall.TIOCM_DTR | syscall.TIOCM_RTS)
+		if err = sys.IoctlPtr(uintptr(fd), syscall.TIOCMBIS, &status); err != nil {
+			return
+		}
+	}
+
+	// fd is non-blocking, so os.NewFile registers it with the poller.
+	f := os.NewFile(uintptr(fd), device)
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		fd = -1 // already closed by f
 		return
 	}
+	s = &Serial{file: f, rc: rc, timeout: opts.ReadTimeout}
+	return
+}
+
+// control runs fn with the descriptor of the port. f.Fd() must not be used:
+// it would put the descriptor back into blocking mode.
+func (this *Serial) control(fn func(fd uintptr) error) (err error) {
+	if e := this.rc.Control(func(fd uintptr) {
+		err = fn(fd)
+	}); e != nil {
+		err = e
+	}
+	return
+}
+
+// ioctl runs an ioctl taking its argument by value on the port.
+func (this *Serial) ioctl(request, v uintptr) error {
+	return this.control(func(fd uintptr) error {
+		return sys.IoctlValue(fd, request, v)
+	})
+}
 
-	s = &serial{uintptr(fd)}
-	runtime.SetFinalizer(s, func(this *serial) {
-		this.Close()
+// ioctlRead runs an ioctl reading a T on port.
+func ioctlRead[T any](port *Serial, request uintptr) (v T, err error) {
+	err = port.control(func(fd uintptr) (err error) {
+		v, err = sys.IoctlRead[T](fd, request)
+		return
 	})
 	return
 }
 
-func (this *serial) Close() error {
-	return syscall.Close(int(this.fd))
+// ioctlWrite runs an ioctl writing v on port.
+func ioctlWrite[T any](port *Serial, request uintptr, v T) error {
+	return port.control(func(fd uintptr) error {
+		return sys.IoctlWrite(fd, request, v)
+	})
+}
+
+func (this *Serial) Close() error {
+	return this.file.Close()
+}
+
+// Flush discards data received but not read, and data written but not sent.
+func (this *Serial) Flush() error {
+	return this.ioctl(TCFLSH, syscall.TCIOFLUSH)
 }
 
-func (this *serial) Flush() error {
-	return sys.Ioctl(this.fd, syscall.TCIOFLUSH, 0)
+// Drain waits until all written data has been sent.
+func (this *Serial) Drain() error {
+	return this.ioctl(TCSBRK, 1)
 }
 
-func (this *serial) Write(p []byte) (n int, err error) {
-	n, err = syscall.Write(int(this.fd), p)
+// SendBreak holds the line low for d.
+func (this *Serial) SendBreak(d time.Duration) (err error) {
+	if err = this.ioctl(syscall.TIOCSBRK, 0); err != nil {
+		return
+	}
+	time.Sleep(d)
+	return this.ioctl(syscall.TIOCCBRK, 0)
+}
+
+// ModemLines returns the state of the modem lines (MODEM_*).
+func (this *Serial) ModemLines() (status int, err error) {
+	v, err := ioctlRead[int32](this, syscall.TIOCMGET)
+	status = int(v)
 	return
 }
 
-func (this *serial) Read(p []byte) (n int, err error) {
-	n, err = syscall.Read(int(this.fd), p)
+// SetModemLines asserts the lines in set and releases the lines in clear.
+func (this *Serial) SetModemLines(set, clear int) (err error) {
+	if set != 0 {
+		if err = ioctlWrite(this, syscall.TIOCMBIS, int32(set)); err != nil {
+			return
+		}
+	}
+	if clear != 0 {
+		err = ioctlWrite(this, syscall.TIOCMBIC, int32(clear))
+	}
 	return
 }
 
-func (this *serial) Available() (n int, err error) {
-	err = sys.Ioctl(this.fd, syscall.TIOCINQ, uintptr(unsafe.Pointer(&n)))
+func (this *Serial) setLine(line int, on bool) error {
+	if on {
+		return this.SetModemLines(line, 0)
+	}
+	return this.SetModemLines(0, line)
+}
+
+func (this *Serial) getLine(line int) (bool, error) {
+	status, err := this.ModemLines()
+	return status&line != 0, err
+}
+
+func (this *Serial) SetDTR(on bool) error { return this.setLine(MODEM_DTR, on) }
+func (this *Serial) SetRTS(on bool) error { return this.setLine(MODEM_RTS, on) }
+func (this *Serial) DTR() (bool, error)   { return this.getLine(MODEM_DTR) }
+func (this *Serial) RTS() (bool, error)   { return this.getLine(MODEM_RTS) }
+func (this *Serial) CTS() (bool, error)   { return this.getLine(MODEM_CTS) }
+func (this *Serial) DSR() (bool, error)   { return this.getLine(MODEM_DSR) }
+func (this *Serial) DCD() (bool, error)   { return this.getLine(MODEM_DCD) }
+func (this *Serial) RI() (bool, error)    { return this.getLine(MODEM_RI) }
+
+// SetReadDeadline sets the deadline for Read; the zero value restores the
+// ReadTimeout the port was opened with.
+func (this *Serial) SetReadDeadline(t time.Time) error {
+	this.m.Lock()
+	defer this.m.Unlock()
+	this.deadline = t
+	return this.file.SetReadDeadline(t)
+}
+
+func (this *Serial) readDeadline() time.Time {
+	defer this.m.Unlock()
+	this.m.Lock()
+	return this.deadline
+}
+
+// SetWriteDeadline sets the deadline for Write.
+func (this *Serial) SetWriteDeadline(t time.Time) error {
+	return this.file.SetWriteDeadline(t)
+}
+
+func (this *Serial) Write(p []byte) (n int, err error) {
+	n, err = this.file.Write(p)
+	return
+}
+
+// Read reads available data, waiting until the read deadline, or for
+// ReadTimeout if no deadline is set. A timeout returns os.ErrDeadlineExceeded.
+func (this *Serial) Read(p []byte) (n int, err error) {
+	if this.readDeadline().IsZero() && this.timeout > 0 {
+		if err = this.file.SetReadDeadline(time.Now().Add(this.timeout)); err != nil {
+			return
+		}
+	}
+	n, err = this.file.Read(p)
 	return
 }
 
-func getBaud(b uint) uint32 {
-	switch b {
-	case 50:
-		return syscall.B50
-	case 75:
-		return syscall.B75
-	case 110:
-		return syscall.B110
-	case 134:
-		return syscall.B134
-	case 150:
-		return syscall.B150
-	case 200:
-		return syscall.B200
-	case 300:
-		return syscall.B300
-	case 600:
-		return syscall.B600
-	case 1200:
-		return syscall.B1200
-	case 1800:
-		return syscall.B1800
-	case 2400:
-		return syscall.B2400
-	case 4800:
-		return syscall.B4800
-	case 9600:
-		return syscall.B9600
-	case 19200:
-		return syscall.B19200
-	case 38400:
-		return syscall.B38400
-	case 57600:
-		return syscall.B57600
-	case 115200:
-		return syscall.B115200
-	case 230400:
-		return syscall.B230400
-	default:
-	}
-	return 0
+// ReadContext is like Read, but also returns when ctx is done.
+func (this *Serial) ReadContext(ctx context.Context, p []byte) (n int, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	deadline := this.readDeadline()
+	d, byCtx := ctx.Deadline()
+	if byCtx = byCtx && (deadline.IsZero() || d.Before(deadline)); byCtx {
+		deadline = d
+	}
+	if deadline.IsZero() && this.timeout > 0 {
+		deadline = time.Now().Add(this.timeout)
+	}
+	if err = this.file.SetReadDeadline(deadline); err != nil {
+		return
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			this.file.SetReadDeadline(aLongTimeAgo)
+		case <-done:
+		}
+	}()
+	n, err = this.file.Read(p)
+	close(done)
+	<-exited
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else if byCtx && os.IsTimeout(err) {
+			err = context.DeadlineExceeded
+		}
+	}
+	this.file.SetReadDeadline(this.readDeadline())
+	return
+}
+
+// Available returns the number of bytes received but not read yet.
+func (this *Serial) Available() (n int, err error) {
+	v, err := ioctlRead[int32](this, syscall.TIOCINQ)
+	n = int(v)
+	return
 }
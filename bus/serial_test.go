@@ -0,0 +1,158 @@
+//go:build linux
+// +build linux
+
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/zyxar/berry/sys"
+)
+
+// openPty returns the master side of a new pseudo terminal and the path of
+// its slave side.
+func openPty(t *testing.T) (*os.File, string) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	if err = sys.IoctlWrite(master.Fd(), syscall.TIOCSPTLCK, int32(0)); err != nil {
+		master.Close()
+		t.Skip(err)
+	}
+	n, err := sys.IoctlRead[uint32](master.Fd(), syscall.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		t.Skip(err)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func TestSerialPty(t *testing.T) {
+	master, slave := openPty(t)
+	defer master.Close()
+	s, err := OpenSerialWithOptions(slave, &SerialOptions{Baud: 1000000, ReadTimeout: 50 * time.Millisecond, KeepModemLines: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	p := make([]byte, 16)
+	start := time.Now()
+	if _, err = s.Read(p); !os.IsTimeout(err) {
+		t.Errorf("read timeout expected: %v", err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond || d > time.Second {
+		t.Errorf("read timeout mismatch: %v", d)
+	}
+
+	if _, err = master.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.Read(p)
+	if err != nil || string(p[:n]) != "hello" {
+		t.Errorf("read mismatch: %q, %v", p[:n], err)
+	}
+
+	if _, err = s.Write([]byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Drain(); err != nil {
+		t.Error(err)
+	}
+	n, err = master.Read(p)
+	if err != nil || string(p[:n]) != "world" {
+		t.Errorf("write mismatch: %q, %v", p[:n], err)
+	}
+
+	// ptys have no modem lines to assert
+	if d, err := OpenSerialWithOptions(slave, nil); err == nil {
+		d.Close()
+	} else if !errors.Is(err, syscall.ENOTTY) {
+		t.Errorf("default options: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.SetReadDeadline(time.Now().Add(time.Minute))
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if _, err = s.ReadContext(ctx, p); err != context.Canceled {
+		t.Errorf("read cancellation expected: %v", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err = s.ReadContext(ctx, p); err != context.DeadlineExceeded {
+		t.Errorf("read deadline expected: %v", err)
+	}
+	master.Write([]byte{0x55})
+	n, err = s.ReadContext(context.Background(), p)
+	if err != nil || n != 1 || p[0] != 0x55 {
+		t.Errorf("read mismatch: %X, %v", p[:n], err)
+	}
+
+	s.SetReadDeadline(time.Now().Add(time.Minute))
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.Close()
+	}()
+	if _, err = s.Read(p); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("%v expected, got %v", os.ErrClosed, err)
+	}
+	if _, err = s.Write(p); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("%v expected, got %v", os.ErrClosed, err)
+	}
+}
+
+type pinRecorder []uint8
+
+func (this *pinRecorder) DigitalWrite(v uint8) error {
+	*this = append(*this, v)
+	return nil
+}
+
+func TestRS485Software(t *testing.T) {
+	if size := unsafe.Sizeof(serialRS485{}); size != 32 {
+		t.Fatalf("serial_rs485 size mismatch: %d", size)
+	}
+	master, slave := openPty(t)
+	defer master.Close()
+	s, err := OpenSerialWithOptions(slave, &SerialOptions{Baud: 9600, ReadTimeout: 50 * time.Millisecond, KeepModemLines: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err = s.SetRS485(&RS485Options{RTSOnSend: true}); err == nil {
+		t.Log("pty accepted TIOCSRS485")
+	}
+
+	de := &pinRecorder{}
+	r, err := NewRS485(s, de, &RS485Options{RTSOnSend: true, DelayAfterSend: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	master.Write([]byte("stale"))
+	time.Sleep(10 * time.Millisecond)
+	if _, err = r.Write([]byte{0x01, 0x03, 0x00, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*de) != 3 || (*de)[0] != 0 || (*de)[1] != 1 || (*de)[2] != 0 {
+		t.Errorf("driver enable sequence mismatch: %v", *de)
+	}
+	p := make([]byte, 16)
+	n, err := master.Read(p)
+	if err != nil || n != 4 || p[1] != 0x03 {
+		t.Errorf("write mismatch: %X, %v", p[:n], err)
+	}
+	if n, err = r.Read(p); err != nil || string(p[:n]) != "stale" {
+		t.Errorf("input received before sending lost: %q, %v", p[:n], err)
+	}
+}
@@ -0,0 +1,134 @@
+package bus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const ttyClassPath = "/sys/class/tty"
+
+var serialPrefixes = []string{"ttyAMA", "ttyS", "ttyUSB", "ttyACM"}
+
+// SerialPort describes a serial device found in sysfs.
+type SerialPort struct {
+	Name   string   // e.g. ttyUSB0
+	Path   string   // e.g. /dev/ttyUSB0
+	Driver string   // kernel driver of the port, if known
+	USB    *USBInfo // nil unless the port sits on a USB device
+}
+
+// USBInfo describes the USB device and interface providing a serial port.
+type USBInfo struct {
+	VendorID     uint16
+	ProductID    uint16
+	Serial       string
+	Manufacturer string
+	Product      string
+	Interface    uint8 // bInterfaceNumber
+}
+
+// SerialPorts lists /dev/ttyAMA*, /dev/ttyS*, /dev/ttyUSB* and /dev/ttyACM*
+// ports, skipping legacy ttyS ports without hardware behind them.
+func SerialPorts() ([]SerialPort, error) {
+	return listSerialPorts("/")
+}
+
+func listSerialPorts(root string) (ports []SerialPort, err error) {
+	class := filepath.Join(root, ttyClassPath)
+	entries, err := ioutil.ReadDir(class)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !isSerialName(name) {
+			continue
+		}
+		dir := filepath.Join(class, name)
+		device, err := filepath.EvalSymlinks(filepath.Join(dir, "device"))
+		if err != nil {
+			continue // virtual terminal
+		}
+		if strings.HasPrefix(name, "ttyS") && readTrimmed(filepath.Join(dir, "type")) == "0" {
+			continue // PORT_UNKNOWN
+		}
+		port := SerialPort{Name: name, Path: filepath.Join("/dev", name)}
+		if link, err := os.Readlink(filepath.Join(device, "driver")); err == nil {
+			port.Driver = filepath.Base(link)
+		}
+		port.USB = usbInfo(root, device)
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool { return lessPortName(ports[i].Name, ports[j].Name) })
+	return ports, nil
+}
+
+func isSerialName(name string) bool {
+	for _, prefix := range serialPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			if _, err := strconv.ParseUint(name[len(prefix):], 10, 32); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lessPortName orders ports by prefix, then numerically.
+func lessPortName(a, b string) bool {
+	ia := strings.IndexAny(a, "0123456789")
+	ib := strings.IndexAny(b, "0123456789")
+	if a[:ia] != b[:ib] {
+		return a[:ia] < b[:ib]
+	}
+	na, _ := strconv.Atoi(a[ia:])
+	nb, _ := strconv.Atoi(b[ib:])
+	return na < nb
+}
+
+// usbInfo walks up from device to the USB interface and device directories.
+func usbInfo(root, device string) *USBInfo {
+	var info *USBInfo
+	for dir := device; len(dir) > len(root) && dir != "/"; dir = filepath.Dir(dir) {
+		if info == nil {
+			if v := readTrimmed(filepath.Join(dir, "bInterfaceNumber")); v != "" {
+				n, _ := strconv.ParseUint(v, 16, 8)
+				info = &USBInfo{Interface: uint8(n)}
+			}
+			continue
+		}
+		vendor := readTrimmed(filepath.Join(dir, "idVendor"))
+		if vendor == "" {
+			continue
+		}
+		v, _ := strconv.ParseUint(vendor, 16, 16)
+		p, _ := strconv.ParseUint(readTrimmed(filepath.Join(dir, "idProduct")), 16, 16)
+		info.VendorID = uint16(v)
+		info.ProductID = uint16(p)
+		info.Serial = readTrimmed(filepath.Join(dir, "serial"))
+		info.Manufacturer = readTrimmed(filepath.Join(dir, "manufacturer"))
+		info.Product = readTrimmed(filepath.Join(dir, "product"))
+		return info
+	}
+	return nil
+}
+
+// SerialPortByUSBSerial returns the port provided by the USB device with the
+// given serial number. Its interface must match too, for multi-port adapters.
+func SerialPortByUSBSerial(serial string, iface uint8) (port SerialPort, err error) {
+	ports, err := SerialPorts()
+	if err != nil {
+		return
+	}
+	for _, port = range ports {
+		if port.USB != nil && port.USB.Serial == serial && port.USB.Interface == iface {
+			return
+		}
+	}
+	port, err = SerialPort{}, os.ErrNotExist
+	return
+}
@@ -0,0 +1,85 @@
+package bus
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func symlinkSysfs(t *testing.T, root, target, path string) {
+	path = filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListSerialPorts(t *testing.T) {
+	root := t.TempDir()
+	const soc = "sys/devices/platform/soc/"
+	writeSysfs(t, root, soc+"3f201000.serial/tty/ttyAMA0/dev", "204:64\n")
+	symlinkSysfs(t, root, "../../../3f201000.serial", soc+"3f201000.serial/tty/ttyAMA0/device")
+	symlinkSysfs(t, root, "../../../../bus/amba/drivers/uart-pl011", soc+"3f201000.serial/driver")
+	symlinkSysfs(t, root, "../../devices/platform/soc/3f201000.serial/tty/ttyAMA0", "sys/class/tty/ttyAMA0")
+
+	writeSysfs(t, root, "sys/devices/platform/serial8250/tty/ttyS0/type", "0\n")
+	symlinkSysfs(t, root, "../../../serial8250", "sys/devices/platform/serial8250/tty/ttyS0/device")
+	symlinkSysfs(t, root, "../../devices/platform/serial8250/tty/ttyS0", "sys/class/tty/ttyS0")
+
+	const ftdi = soc + "usb/usb1/1-1/1-1.2/"
+	writeSysfs(t, root, ftdi+"idVendor", "0403\n")
+	writeSysfs(t, root, ftdi+"idProduct", "6001\n")
+	writeSysfs(t, root, ftdi+"serial", "A50285BI\n")
+	writeSysfs(t, root, ftdi+"manufacturer", "FTDI\n")
+	writeSysfs(t, root, ftdi+"product", "FT232R USB UART\n")
+	writeSysfs(t, root, ftdi+"1-1.2:1.0/bInterfaceNumber", "00\n")
+	writeSysfs(t, root, ftdi+"1-1.2:1.0/ttyUSB0/tty/ttyUSB0/dev", "188:0\n")
+	symlinkSysfs(t, root, "../../../ttyUSB0", ftdi+"1-1.2:1.0/ttyUSB0/tty/ttyUSB0/device")
+	symlinkSysfs(t, root, "../../../../../../../../bus/usb-serial/drivers/ftdi_sio", ftdi+"1-1.2:1.0/ttyUSB0/driver")
+	symlinkSysfs(t, root, "../../devices/platform/soc/usb/usb1/1-1/1-1.2/1-1.2:1.0/ttyUSB0/tty/ttyUSB0", "sys/class/tty/ttyUSB0")
+
+	const acm = soc + "usb/usb1/1-1/1-1.3/"
+	writeSysfs(t, root, acm+"idVendor", "2341\n")
+	writeSysfs(t, root, acm+"idProduct", "0043\n")
+	writeSysfs(t, root, acm+"serial", "75633313233351F0A1E1\n")
+	writeSysfs(t, root, acm+"1-1.3:1.2/bInterfaceNumber", "02\n")
+	writeSysfs(t, root, acm+"1-1.3:1.2/tty/ttyACM10/dev", "166:10\n")
+	symlinkSysfs(t, root, "../../../1-1.3:1.2", acm+"1-1.3:1.2/tty/ttyACM10/device")
+	symlinkSysfs(t, root, "../../devices/platform/soc/usb/usb1/1-1/1-1.3/1-1.3:1.2/tty/ttyACM10", "sys/class/tty/ttyACM10")
+	writeSysfs(t, root, acm+"1-1.3:1.2/tty/ttyACM9/dev", "166:9\n")
+	symlinkSysfs(t, root, "../../../1-1.3:1.2", acm+"1-1.3:1.2/tty/ttyACM9/device")
+	symlinkSysfs(t, root, "../../devices/platform/soc/usb/usb1/1-1/1-1.3/1-1.3:1.2/tty/ttyACM9", "sys/class/tty/ttyACM9")
+
+	writeSysfs(t, root, "sys/devices/virtual/tty/tty1/dev", "4:1\n")
+	symlinkSysfs(t, root, "../../devices/virtual/tty/tty1", "sys/class/tty/tty1")
+
+	ports, err := listSerialPorts(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ports) != 4 {
+		t.Fatalf("port number mismatch: %+v", ports)
+	}
+	for i, name := range []string{"ttyACM9", "ttyACM10", "ttyAMA0", "ttyUSB0"} {
+		if ports[i].Name != name || ports[i].Path != "/dev/"+name {
+			t.Errorf("port mismatch: %+v", ports[i])
+		}
+	}
+	if p := ports[2]; p.Driver != "uart-pl011" || p.USB != nil {
+		t.Errorf("ttyAMA0 mismatch: %+v", p)
+	}
+	if u := ports[1].USB; u == nil || u.VendorID != 0x2341 || u.ProductID != 0x0043 ||
+		u.Serial != "75633313233351F0A1E1" || u.Interface != 2 {
+		t.Errorf("ttyACM10 mismatch: %+v", u)
+	}
+	p := ports[3]
+	if p.Driver != "ftdi_sio" {
+		t.Errorf("ttyUSB0 driver mismatch: %q", p.Driver)
+	}
+	if u := p.USB; u == nil || u.VendorID != 0x0403 || u.ProductID != 0x6001 || u.Serial != "A50285BI" ||
+		u.Manufacturer != "FTDI" || u.Product != "FT232R USB UART" || u.Interface != 0 {
+		t.Errorf("ttyUSB0 mismatch: %+v", u)
+	}
+}
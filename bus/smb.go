@@ -2,7 +2,7 @@ package bus
 
 import (
 	"encoding/binary"
-	"unsafe"
+	"errors"
 
 	"github.com/zyxar/berry/sys"
 )
@@ -56,31 +56,96 @@ const (
 	I2C_FUNC_SMBUS_I2C_BLOCK  = (I2C_FUNC_SMBUS_READ_I2C_BLOCK | I2C_FUNC_SMBUS_WRITE_I2C_BLOCK)
 )
 
+var (
+	errInvalidBlockSize = errors.New("invalid block size")
+	errInvalidDataSize  = errors.New("invalid data size for smbus transaction")
+)
+
 type smbusData [SMBUS_BLOCK_MAX + 2]uint8
 
+// smbusIoctlData is struct i2c_smbus_ioctl_data from <linux/i2c-dev.h>.
 type smbusIoctlData struct {
 	rw   uint8
 	cmd  uint8
-	size int
+	size uint32
 	data *smbusData
 }
 
+// smbusIoctl issues I2C_SMBUS; tests replace it to inspect the request.
+var smbusIoctl = func(fd uintptr, d *smbusIoctlData) error {
+	return sys.IoctlPtr(fd, I2C_SMBUS, d)
+}
+
 func smbusAccess(fd uintptr, rw uint8, cmd uint8, size int, data *smbusData) error {
 	d := smbusIoctlData{
 		rw:   rw,
 		cmd:  cmd,
-		size: size,
+		size: uint32(size),
 		data: data,
 	}
-	return sys.Ioctl(fd, I2C_SMBUS, uintptr(unsafe.Pointer(&d)))
+	return smbusIoctl(fd, &d)
 }
 
 func SMBusWriteQuick(fd uintptr, b uint8) error {
 	return smbusAccess(fd, b, 0, SMBUS_QUICK, nil)
 }
 
+// SMBusReadQuick sends a single read bit to the device, without any data.
+func SMBusReadQuick(fd uintptr) error {
+	return smbusAccess(fd, SMBUS_READ, 0, SMBUS_QUICK, nil)
+}
+
+// SMBusReadBlock reads an SMBus block, whose length is reported by the device.
+func SMBusReadBlock(fd uintptr, cmd uint8) (b []byte, err error) {
+	return SMBusRead(fd, cmd, SMBUS_BLOCK_DATA)
+}
+
+// SMBusWriteBlock writes an SMBus block, prefixed with its length on the wire.
+func SMBusWriteBlock(fd uintptr, cmd uint8, b []byte) error {
+	if len(b) == 0 || len(b) > SMBUS_BLOCK_MAX {
+		return errInvalidBlockSize
+	}
+	var data smbusData
+	data[0] = uint8(len(b))
+	copy(data[1:], b)
+	return smbusAccess(fd, SMBUS_WRITE, cmd, SMBUS_BLOCK_DATA, &data)
+}
+
+// SMBusReadI2CBlock reads length bytes starting at register cmd, without a
+// length prefix on the wire (as most EEPROMs and sensors expect).
+func SMBusReadI2CBlock(fd uintptr, cmd uint8, length int) (b []byte, err error) {
+	if length <= 0 || length > SMBUS_I2C_BLOCK_MAX {
+		err = errInvalidBlockSize
+		return
+	}
+	var data smbusData
+	data[0] = uint8(length)
+	if err = smbusAccess(fd, SMBUS_READ, cmd, SMBUS_I2C_BLOCK_DATA, &data); err != nil {
+		return
+	}
+	if l := data[0]; l > 0 {
+		b = data[1 : l+1]
+	}
+	return
+}
+
+// SMBusWriteI2CBlock writes b starting at register cmd, without a length
+// prefix on the wire.
+func SMBusWriteI2CBlock(fd uintptr, cmd uint8, b []byte) error {
+	if len(b) == 0 || len(b) > SMBUS_I2C_BLOCK_MAX {
+		return errInvalidBlockSize
+	}
+	var data smbusData
+	data[0] = uint8(len(b))
+	copy(data[1:], b)
+	return smbusAccess(fd, SMBUS_WRITE, cmd, SMBUS_I2C_BLOCK_DATA, &data)
+}
+
 func SMBusRead(fd uintptr, cmd uint8, size int) (b []byte, err error) {
 	var data smbusData
+	if size == SMBUS_I2C_BLOCK_DATA {
+		data[0] = SMBUS_I2C_BLOCK_MAX
+	}
 	if err = smbusAccess(fd, SMBUS_READ, cmd, size, &data); err != nil {
 		return
 	}
@@ -89,7 +154,7 @@ func SMBusRead(fd uintptr, cmd uint8, size int) (b []byte, err error) {
 		b = data[:1]
 	case SMBUS_WORD_DATA:
 		b = data[:2]
-	case SMBUS_BLOCK_DATA:
+	case SMBUS_BLOCK_DATA, SMBUS_I2C_BLOCK_DATA:
 		if l := data[0]; l > 0 {
 			b = data[1 : l+1]
 		}
@@ -97,24 +162,50 @@ func SMBusRead(fd uintptr, cmd uint8, size int) (b []byte, err error) {
 	return
 }
 
+// SMBusWrite writes cmd alone, or followed by a byte or a word, depending on
+// len(b). Longer writes must choose their framing with SMBusWriteBlock,
+// SMBusWriteI2CBlock or SMBusWriteSize.
 func SMBusWrite(fd uintptr, cmd uint8, b ...uint8) error {
-	length := len(b)
-	if length == 0 {
-		return smbusAccess(fd, SMBUS_WRITE, cmd, SMBUS_BYTE, nil)
-	}
-	var data smbusData
 	var size int
-	switch length {
+	switch len(b) {
+	case 0:
+		size = SMBUS_BYTE
 	case 1:
-		data[0] = b[0]
 		size = SMBUS_BYTE_DATA
 	case 2:
-		copy(data[:], b)
 		size = SMBUS_WORD_DATA
 	default:
-		data[0] = uint8(length)
-		copy(data[1:], b)
-		size = SMBUS_BLOCK_DATA
+		return errInvalidDataSize
+	}
+	return SMBusWriteSize(fd, cmd, size, b...)
+}
+
+// SMBusWriteSize writes b after cmd as the given transaction type, which
+// must agree with len(b).
+func SMBusWriteSize(fd uintptr, cmd uint8, size int, b ...uint8) error {
+	var data smbusData
+	switch size {
+	case SMBUS_BYTE:
+		if len(b) != 0 {
+			return errInvalidDataSize
+		}
+		return smbusAccess(fd, SMBUS_WRITE, cmd, SMBUS_BYTE, nil)
+	case SMBUS_BYTE_DATA:
+		if len(b) != 1 {
+			return errInvalidDataSize
+		}
+		data[0] = b[0]
+	case SMBUS_WORD_DATA:
+		if len(b) != 2 {
+			return errInvalidDataSize
+		}
+		copy(data[:], b)
+	case SMBUS_BLOCK_DATA:
+		return SMBusWriteBlock(fd, cmd, b)
+	case SMBUS_I2C_BLOCK_DATA:
+		return SMBusWriteI2CBlock(fd, cmd, b)
+	default:
+		return errInvalidDataSize
 	}
 	return smbusAccess(fd, SMBUS_WRITE, cmd, size, &data)
 }
@@ -0,0 +1,158 @@
+package bus
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"syscall"
+	"testing"
+)
+
+// smbusRecorder replaces smbusIoctl, recording each request and answering
+// reads with reply.
+type smbusRecorder struct {
+	requests []smbusIoctlData
+	sent     [][]byte // data block as passed to the kernel, nil if none
+	reply    []byte
+}
+
+func recordSMBus(t *testing.T, reply []byte) *smbusRecorder {
+	r := &smbusRecorder{reply: reply}
+	saved := smbusIoctl
+	smbusIoctl = func(fd uintptr, d *smbusIoctlData) error {
+		r.requests = append(r.requests, *d)
+		if d.data == nil {
+			r.sent = append(r.sent, nil)
+			return nil
+		}
+		r.sent = append(r.sent, append([]byte(nil), d.data[:]...))
+		if d.rw == SMBUS_READ {
+			copy(d.data[:], r.reply)
+		}
+		return nil
+	}
+	t.Cleanup(func() { smbusIoctl = saved })
+	return r
+}
+
+func (this *smbusRecorder) check(t *testing.T, rw, cmd uint8, size int, data []byte) {
+	t.Helper()
+	if len(this.requests) != 1 {
+		t.Fatalf("request number mismatch: %d", len(this.requests))
+	}
+	d := this.requests[0]
+	if d.rw != rw || d.cmd != cmd || d.size != uint32(size) {
+		t.Errorf("request mismatch: rw=%d cmd=0x%02X size=%d", d.rw, d.cmd, d.size)
+	}
+	if data == nil {
+		if this.sent[0] != nil {
+			t.Errorf("unexpected data block: %X", this.sent[0])
+		}
+	} else if !bytes.HasPrefix(this.sent[0], data) {
+		t.Errorf("data block mismatch: %X, expected prefix %X", this.sent[0], data)
+	}
+	this.requests, this.sent = nil, nil
+}
+
+func TestSMBusRead(t *testing.T) {
+	r := recordSMBus(t, []byte{3, 0xA1, 0xA2, 0xA3, 0xFF})
+	if err := SMBusReadQuick(0); err != nil {
+		t.Fatal(err)
+	}
+	r.check(t, SMBUS_READ, 0, SMBUS_QUICK, nil)
+
+	b, err := SMBusReadBlock(0, 0x10)
+	if err != nil || !bytes.Equal(b, []byte{0xA1, 0xA2, 0xA3}) {
+		t.Errorf("block mismatch: %X, %v", b, err)
+	}
+	r.check(t, SMBUS_READ, 0x10, SMBUS_BLOCK_DATA, []byte{0})
+
+	b, err = SMBusReadI2CBlock(0, 0x20, 3)
+	if err != nil || !bytes.Equal(b, []byte{0xA1, 0xA2, 0xA3}) {
+		t.Errorf("i2c block mismatch: %X, %v", b, err)
+	}
+	r.check(t, SMBUS_READ, 0x20, SMBUS_I2C_BLOCK_DATA, []byte{3})
+
+	for _, length := range []int{0, -1, SMBUS_I2C_BLOCK_MAX + 1} {
+		if _, err = SMBusReadI2CBlock(0, 0x20, length); err != errInvalidBlockSize {
+			t.Errorf("i2c block length %d accepted: %v", length, err)
+		}
+	}
+	if len(r.requests) != 0 {
+		t.Errorf("invalid reads reached the adapter: %d", len(r.requests))
+	}
+}
+
+func TestSMBusWrite(t *testing.T) {
+	r := recordSMBus(t, nil)
+	for _, c := range []struct {
+		b    []byte
+		size int
+		data []byte
+	}{
+		{nil, SMBUS_BYTE, nil},
+		{[]byte{0x12}, SMBUS_BYTE_DATA, []byte{0x12}},
+		{[]byte{0x34, 0x12}, SMBUS_WORD_DATA, []byte{0x34, 0x12}},
+	} {
+		if err := SMBusWrite(0, 0x30, c.b...); err != nil {
+			t.Fatal(err)
+		}
+		r.check(t, SMBUS_WRITE, 0x30, c.size, c.data)
+	}
+	if err := SMBusWrite(0, 0x30, 1, 2, 3); err != errInvalidDataSize {
+		t.Errorf("implicit block write accepted: %v", err)
+	}
+
+	if err := SMBusWriteSize(0, 0x40, SMBUS_BLOCK_DATA, 1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	r.check(t, SMBUS_WRITE, 0x40, SMBUS_BLOCK_DATA, []byte{3, 1, 2, 3})
+	if err := SMBusWriteSize(0, 0x40, SMBUS_I2C_BLOCK_DATA, 1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	r.check(t, SMBUS_WRITE, 0x40, SMBUS_I2C_BLOCK_DATA, []byte{3, 1, 2, 3})
+	if err := SMBusWriteI2CBlock(0, 0x50, []byte{0xEE}); err != nil {
+		t.Fatal(err)
+	}
+	r.check(t, SMBUS_WRITE, 0x50, SMBUS_I2C_BLOCK_DATA, []byte{1, 0xEE})
+
+	for _, c := range []struct {
+		size int
+		b    []byte
+		err  error
+	}{
+		{SMBUS_BYTE, []byte{1}, errInvalidDataSize},
+		{SMBUS_BYTE_DATA, nil, errInvalidDataSize},
+		{SMBUS_WORD_DATA, []byte{1, 2, 3}, errInvalidDataSize},
+		{SMBUS_QUICK, nil, errInvalidDataSize},
+		{SMBUS_BLOCK_DATA, nil, errInvalidBlockSize},
+		{SMBUS_I2C_BLOCK_DATA, make([]byte, SMBUS_I2C_BLOCK_MAX+1), errInvalidBlockSize},
+	} {
+		if err := SMBusWriteSize(0, 0x40, c.size, c.b...); err != c.err {
+			t.Errorf("size %d with %d bytes: %v, expected %v", c.size, len(c.b), err, c.err)
+		}
+	}
+	if len(r.requests) != 0 {
+		t.Errorf("invalid writes reached the adapter: %d", len(r.requests))
+	}
+}
+
+func TestSetPEC(t *testing.T) {
+	f, err := ioutil.TempFile(t.TempDir(), "i2c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	i2c := &I2C{rc: f}
+	if err = i2c.SetPEC(true); err != errPECUnsupported {
+		t.Errorf("pec enabled without adapter support: %v", err)
+	}
+	// a regular file rejects the ioctl, which shows it was issued
+	if err = i2c.SetPEC(false); !errors.Is(err, syscall.ENOTTY) {
+		t.Errorf("pec disable not issued: %v", err)
+	}
+	i2c.mask = I2C_FUNC_SMBUS_PEC
+	if err = i2c.SetPEC(true); !errors.Is(err, syscall.ENOTTY) {
+		t.Errorf("pec enable not issued: %v", err)
+	}
+}
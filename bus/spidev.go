@@ -1,6 +1,8 @@
 package bus
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"runtime"
@@ -11,10 +13,28 @@ import (
 
 const (
 	spiIoctlMAGIC = 'k'
-	spiDev0       = "/dev/spidev0.0"
-	spiDev1       = "/dev/spidev0.1"
+	spiDevPath    = "/dev/spidev%d.%d"
 )
 
+const (
+	SPI_CPHA      = 0x01
+	SPI_CPOL      = 0x02
+	SPI_MODE_0    = (0 | 0)
+	SPI_MODE_1    = (0 | SPI_CPHA)
+	SPI_MODE_2    = (SPI_CPOL | 0)
+	SPI_MODE_3    = (SPI_CPOL | SPI_CPHA)
+	SPI_CS_HIGH   = 0x04
+	SPI_LSB_FIRST = 0x08
+	SPI_3WIRE     = 0x10
+	SPI_LOOP      = 0x20
+	SPI_NO_CS     = 0x40
+	SPI_READY     = 0x80
+	SPI_TX_DUAL   = 0x100
+	SPI_TX_QUAD   = 0x200
+	SPI_RX_DUAL   = 0x400
+	SPI_RX_QUAD   = 0x800
+) // from <linux/spi/spidev.h>
+
 type spiIoctlTransfer struct {
 	TxBuf, RxBuf          uint64
 	Length, SpeedHz       uint32
@@ -23,54 +43,129 @@ type spiIoctlTransfer struct {
 	_                     uint32
 }
 
-type spi struct {
-	channel uint8
+// SPIOptions configures a device opened by OpenSPIWithOptions.
+type SPIOptions struct {
+	Mode        uint32 // SPI_MODE_* combined with SPI_CS_HIGH, SPI_LSB_FIRST, SPI_3WIRE, SPI_LOOP, SPI_NO_CS...
+	BitsPerWord uint8  // word length; 0 means 8
+	MaxSpeedHz  uint32 // 0 keeps the device default
+}
+
+// SPISegment is one transfer of an SPI message. Tx or Rx may be nil for half
+// duplex; if both are set they must have the same length.
+type SPISegment struct {
+	Tx, Rx      []byte
+	SpeedHz     uint32 // 0 uses the device speed
+	DelayUsecs  uint16 // delay after this segment, before CS change or the next segment
+	BitsPerWord uint8  // 0 uses the device word length
+	CSChange    bool   // deselect the device after this segment
+}
+
+var (
+	errSegmentLength   = errors.New("tx and rx length mismatch")
+	errTooManySegments = errors.New("too many segments")
+)
+
+// SPI is a connection to a spidev device.
+type SPI struct {
+	bus, cs uint
+	mode    uint32
+	bpw     uint8
 	speed   uint32
 	file    *os.File
 }
 
-var spiBPW uint8 = 8
-
 type SPIBus interface {
 	io.ReadWriteCloser
 	WriteAndRead(p []byte) (n int, err error)
 }
 
+// OpenSPI opens /dev/spidev0.<channel&1>.
 func OpenSPI(channel uint8, speed uint32, mode uint8) (device SPIBus, err error) {
 	channel &= 1 // 0 or 1
 	mode &= 3    // 0, 1, 2 or 3
-	s := &spi{channel: channel, speed: speed}
+	s, err := OpenSPIWithOptions(0, uint(channel), &SPIOptions{Mode: uint32(mode), MaxSpeedHz: speed})
+	if err != nil {
+		return
+	}
+	device = s
+	return
+}
+
+// OpenSPIWithOptions opens /dev/spidev<bus>.<cs>, configured by opts. The
+// values negotiated with the driver are read back, see Mode, BitsPerWord and
+// MaxSpeedHz.
+func OpenSPIWithOptions(bus, cs uint, opts *SPIOptions) (s *SPI, err error) {
+	if opts == nil {
+		opts = &SPIOptions{}
+	}
+	s = &SPI{bus: bus, cs: cs}
 	defer func() {
 		if err != nil {
 			s.Close()
+			s = nil
 		}
 	}()
-	if channel == 0 {
-		if s.file, err = os.OpenFile(spiDev0, os.O_RDWR, 0); err != nil {
+	if s.file, err = os.OpenFile(fmt.Sprintf(spiDevPath, bus, cs), os.O_RDWR, 0); err != nil {
+		return
+	}
+	fd := s.file.Fd()
+	if opts.Mode > 0xFF {
+		if err = sys.IoctlWrite(fd, SPI_IOC_WR_MODE32(), opts.Mode); err != nil {
+			return
+		}
+		if s.mode, err = sys.IoctlRead[uint32](fd, SPI_IOC_RD_MODE32()); err != nil {
 			return
 		}
 	} else {
-		if s.file, err = os.OpenFile(spiDev1, os.O_RDWR, 0); err != nil {
+		mode := uint8(opts.Mode)
+		if err = sys.IoctlWrite(fd, SPI_IOC_WR_MODE(), mode); err != nil {
+			return
+		}
+		if mode, err = sys.IoctlRead[uint8](fd, SPI_IOC_RD_MODE()); err != nil {
 			return
 		}
+		s.mode = uint32(mode)
 	}
-	if err = sys.Ioctl(s.file.Fd(), SPI_IOC_WR_MODE(), uintptr(unsafe.Pointer(&mode))); err != nil {
+	bpw := opts.BitsPerWord
+	if bpw == 0 {
+		bpw = 8
+	}
+	if err = sys.IoctlWrite(fd, SPI_IOC_WR_BITS_PER_WORD(), bpw); err != nil {
 		return
 	}
-	if err = sys.Ioctl(s.file.Fd(), SPI_IOC_WR_BITS_PER_WORD(), uintptr(unsafe.Pointer(&spiBPW))); err != nil {
+	if s.bpw, err = sys.IoctlRead[uint8](fd, SPI_IOC_RD_BITS_PER_WORD()); err != nil {
 		return
 	}
-	if err = sys.Ioctl(s.file.Fd(), SPI_IOC_WR_MAX_SPEED_HZ(), uintptr(unsafe.Pointer(&speed))); err != nil {
+	if speed := opts.MaxSpeedHz; speed > 0 {
+		if err = sys.IoctlWrite(fd, SPI_IOC_WR_MAX_SPEED_HZ(), speed); err != nil {
+			return
+		}
+	}
+	if s.speed, err = sys.IoctlRead[uint32](fd, SPI_IOC_RD_MAX_SPEED_HZ()); err != nil {
 		return
 	}
-	runtime.SetFinalizer(s, func(this *spi) {
+	runtime.SetFinalizer(s, func(this *SPI) {
 		this.Close()
 	})
-	device = s
 	return
 }
 
-func (this *spi) WriteAndRead(p []byte) (n int, err error) {
+// Mode returns the SPI_* mode flags in effect.
+func (this *SPI) Mode() uint32 {
+	return this.mode
+}
+
+// BitsPerWord returns the word length in effect.
+func (this *SPI) BitsPerWord() uint8 {
+	return this.bpw
+}
+
+// MaxSpeedHz returns the default transfer speed in effect.
+func (this *SPI) MaxSpeedHz() uint32 {
+	return this.speed
+}
+
+func (this *SPI) WriteAndRead(p []byte) (n int, err error) {
 	n = len(p)
 	var transfer = spiIoctlTransfer{
 		TxBuf:       uint64(uintptr(unsafe.Pointer(&p[0]))),
@@ -78,23 +173,76 @@ func (this *spi) WriteAndRead(p []byte) (n int, err error) {
 		Length:      uint32(n),
 		SpeedHz:     this.speed,
 		DelayUsecs:  0,
-		BitsPerWord: spiBPW,
+		BitsPerWord: this.bpw,
+	}
+	err = sys.IoctlPtr(this.file.Fd(), SPI_IOC_MESSAGE(1), &transfer)
+	runtime.KeepAlive(p)
+	return
+}
+
+// Transfer sends segs as a single message, in one ioctl, keeping the device
+// selected between segments unless CSChange is set.
+func (this *SPI) Transfer(segs ...SPISegment) (err error) {
+	if len(segs) == 0 {
+		return
+	}
+	transfers, err := spiTransfers(segs, this.speed, this.bpw)
+	if err != nil {
+		return
+	}
+	err = sys.IoctlSlice(this.file.Fd(), SPI_IOC_MESSAGE(uintptr(len(transfers))), transfers)
+	runtime.KeepAlive(segs)
+	return
+}
+
+func spiTransfers(segs []SPISegment, speed uint32, bpw uint8) (transfers []spiIoctlTransfer, err error) {
+	if SPI_MESSAGE_SIZE(uintptr(len(segs))) == 0 {
+		err = errTooManySegments
+		return
+	}
+	transfers = make([]spiIoctlTransfer, len(segs))
+	for i := range segs {
+		seg := &segs[i]
+		t := &transfers[i]
+		if seg.Tx != nil && seg.Rx != nil && len(seg.Tx) != len(seg.Rx) {
+			err = errSegmentLength
+			return
+		}
+		if len(seg.Tx) > 0 {
+			t.TxBuf = uint64(uintptr(unsafe.Pointer(&seg.Tx[0])))
+			t.Length = uint32(len(seg.Tx))
+		}
+		if len(seg.Rx) > 0 {
+			t.RxBuf = uint64(uintptr(unsafe.Pointer(&seg.Rx[0])))
+			t.Length = uint32(len(seg.Rx))
+		}
+		t.SpeedHz = seg.SpeedHz
+		if t.SpeedHz == 0 {
+			t.SpeedHz = speed
+		}
+		t.BitsPerWord = seg.BitsPerWord
+		if t.BitsPerWord == 0 {
+			t.BitsPerWord = bpw
+		}
+		t.DelayUsecs = seg.DelayUsecs
+		if seg.CSChange {
+			t.CsChange = 1
+		}
 	}
-	err = sys.Ioctl(this.file.Fd(), SPI_IOC_MESSAGE(1), uintptr(unsafe.Pointer(&transfer)))
 	return
 }
 
-func (this *spi) Read(p []byte) (n int, err error) {
+func (this *SPI) Read(p []byte) (n int, err error) {
 	n, err = this.WriteAndRead(p)
 	return
 }
 
-func (this *spi) Write(p []byte) (n int, err error) {
+func (this *SPI) Write(p []byte) (n int, err error) {
 	n, err = this.WriteAndRead(p)
 	return
 }
 
-func (this *spi) Close() (err error) {
+func (this *SPI) Close() (err error) {
 	if this.file != nil {
 		err = this.file.Close()
 		this.file = nil
@@ -104,42 +252,52 @@ func (this *spi) Close() (err error) {
 
 // Read of SPI mode (SPI_MODE_0..SPI_MODE_3)
 func SPI_IOC_RD_MODE() uintptr {
-	return sys.IOR(spiIoctlMAGIC, 1, 1)
+	return sys.IOROf[uint8](spiIoctlMAGIC, 1)
 }
 
 // Write of SPI mode (SPI_MODE_0..SPI_MODE_3)
 func SPI_IOC_WR_MODE() uintptr {
-	return sys.IOW(spiIoctlMAGIC, 1, 1)
+	return sys.IOWOf[uint8](spiIoctlMAGIC, 1)
 }
 
 // Read SPI bit justification
 func SPI_IOC_RD_LSB_FIRST() uintptr {
-	return sys.IOR(spiIoctlMAGIC, 2, 1)
+	return sys.IOROf[uint8](spiIoctlMAGIC, 2)
 }
 
 // Write SPI bit justification
 func SPI_IOC_WR_LSB_FIRST() uintptr {
-	return sys.IOW(spiIoctlMAGIC, 2, 1)
+	return sys.IOWOf[uint8](spiIoctlMAGIC, 2)
 }
 
 // Read SPI device word length (1..N)
 func SPI_IOC_RD_BITS_PER_WORD() uintptr {
-	return sys.IOR(spiIoctlMAGIC, 3, 1)
+	return sys.IOROf[uint8](spiIoctlMAGIC, 3)
 }
 
 // Write SPI device word length (1..N)
 func SPI_IOC_WR_BITS_PER_WORD() uintptr {
-	return sys.IOW(spiIoctlMAGIC, 3, 1)
+	return sys.IOWOf[uint8](spiIoctlMAGIC, 3)
 }
 
 // Read SPI device default max speed hz
 func SPI_IOC_RD_MAX_SPEED_HZ() uintptr {
-	return sys.IOR(spiIoctlMAGIC, 4, 4)
+	return sys.IOROf[uint32](spiIoctlMAGIC, 4)
 }
 
 // Write SPI device default max speed hz
 func SPI_IOC_WR_MAX_SPEED_HZ() uintptr {
-	return sys.IOW(spiIoctlMAGIC, 4, 4)
+	return sys.IOWOf[uint32](spiIoctlMAGIC, 4)
+}
+
+// Read of SPI mode field (32 bits)
+func SPI_IOC_RD_MODE32() uintptr {
+	return sys.IOROf[uint32](spiIoctlMAGIC, 5)
+}
+
+// Write of SPI mode field (32 bits)
+func SPI_IOC_WR_MODE32() uintptr {
+	return sys.IOWOf[uint32](spiIoctlMAGIC, 5)
 }
 
 // Write custom SPI message
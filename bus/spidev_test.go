@@ -0,0 +1,42 @@
+package bus
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestSPITransfers(t *testing.T) {
+	if size := unsafe.Sizeof(spiIoctlTransfer{}); size != 32 {
+		t.Fatalf("spi_ioc_transfer size mismatch: %d", size)
+	}
+	cmd := []byte{0x03, 0x00, 0x10, 0x00}
+	data := make([]byte, 256)
+	transfers, err := spiTransfers([]SPISegment{
+		{Tx: cmd},
+		{Rx: data, SpeedHz: 20000000, DelayUsecs: 10, CSChange: true},
+		{Tx: cmd[:1], Rx: data[:1], BitsPerWord: 9},
+	}, 1000000, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transfers) != 3 {
+		t.Fatalf("transfer number mismatch: %d", len(transfers))
+	}
+	if tr := transfers[0]; tr.TxBuf != uint64(uintptr(unsafe.Pointer(&cmd[0]))) || tr.RxBuf != 0 ||
+		tr.Length != 4 || tr.SpeedHz != 1000000 || tr.BitsPerWord != 8 || tr.CsChange != 0 {
+		t.Errorf("transfer mismatch: %+v", tr)
+	}
+	if tr := transfers[1]; tr.TxBuf != 0 || tr.RxBuf != uint64(uintptr(unsafe.Pointer(&data[0]))) ||
+		tr.Length != 256 || tr.SpeedHz != 20000000 || tr.DelayUsecs != 10 || tr.CsChange != 1 {
+		t.Errorf("transfer mismatch: %+v", tr)
+	}
+	if tr := transfers[2]; tr.TxBuf == 0 || tr.RxBuf == 0 || tr.Length != 1 || tr.BitsPerWord != 9 {
+		t.Errorf("transfer mismatch: %+v", tr)
+	}
+	if _, err = spiTransfers([]SPISegment{{Tx: cmd, Rx: data}}, 0, 8); err != errSegmentLength {
+		t.Errorf("length mismatch not detected: %v", err)
+	}
+	if _, err = spiTransfers(make([]SPISegment, 512), 0, 8); err != errTooManySegments {
+		t.Errorf("segment overflow not detected: %v", err)
+	}
+}
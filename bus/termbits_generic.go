@@ -0,0 +1,42 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le && !ppc64 && !ppc64le
+// +build linux,!mips,!mipsle,!mips64,!mips64le,!ppc64,!ppc64le
+
+package bus
+
+import "github.com/zyxar/berry/sys"
+
+const (
+	BOTHER  = 0010000      /* c_cflag: use c_ispeed/c_ospeed for arbitrary rates */
+	CBAUD   = 0010017      /* c_cflag: baud rate mask */
+	CIBAUD  = 002003600000 /* c_cflag: input baud rate mask */
+	CMSPAR  = 010000000000 /* c_cflag: mark or space (stick) parity */
+	CRTSCTS = 020000000000 /* c_cflag: flow control */
+) // from <asm-generic/termbits.h>
+
+const (
+	TCSBRK        = 0x5409 /* tcsendbreak, or tcdrain with a non-zero argument */
+	TCFLSH        = 0x540B /* tcflush */
+	TIOCGRS485    = 0x542E
+	TIOCSRS485    = 0x542F
+	TIOCSERGETLSR = 0x5459 /* get line status register */
+) // from <asm-generic/ioctls.h>
+
+// termios2 is struct termios2 from <asm-generic/termbits.h>.
+type termios2 struct {
+	Iflag  uint32
+	Oflag  uint32
+	Cflag  uint32
+	Lflag  uint32
+	Line   uint8
+	Cc     [19]uint8
+	Ispeed uint32
+	Ospeed uint32
+}
+
+func TCGETS2() uintptr {
+	return sys.IOROf[termios2]('T', 0x2A)
+}
+
+func TCSETS2() uintptr {
+	return sys.IOWOf[termios2]('T', 0x2B)
+}
@@ -0,0 +1,43 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le)
+// +build linux
+// +build mips mipsle mips64 mips64le
+
+package bus
+
+import "github.com/zyxar/berry/sys"
+
+const (
+	BOTHER  = 0010000      /* c_cflag: use c_ispeed/c_ospeed for arbitrary rates */
+	CBAUD   = 0010017      /* c_cflag: baud rate mask */
+	CIBAUD  = 002003600000 /* c_cflag: input baud rate mask */
+	CMSPAR  = 010000000000 /* c_cflag: mark or space (stick) parity */
+	CRTSCTS = 020000000000 /* c_cflag: flow control */
+) // from <asm/termbits.h>
+
+const (
+	TCSBRK        = 0x5405     /* tcsendbreak, or tcdrain with a non-zero argument */
+	TCFLSH        = 0x5407     /* tcflush */
+	TIOCGRS485    = 0x4020542E /* _IOR('T', 0x2E, struct serial_rs485) */
+	TIOCSRS485    = 0xC020542F /* _IOWR('T', 0x2F, struct serial_rs485) */
+	TIOCSERGETLSR = 0x548E     /* get line status register */
+) // from <asm/ioctls.h>
+
+// termios2 is struct termios2 from <asm/termbits.h>, with 23 control chars.
+type termios2 struct {
+	Iflag  uint32
+	Oflag  uint32
+	Cflag  uint32
+	Lflag  uint32
+	Line   uint8
+	Cc     [23]uint8
+	Ispeed uint32
+	Ospeed uint32
+}
+
+func TCGETS2() uintptr {
+	return sys.IOROf[termios2]('T', 0x2A)
+}
+
+func TCSETS2() uintptr {
+	return sys.IOWOf[termios2]('T', 0x2B)
+}
@@ -0,0 +1,47 @@
+//go:build linux && (ppc64 || ppc64le)
+// +build linux
+// +build ppc64 ppc64le
+
+package bus
+
+import "github.com/zyxar/berry/sys"
+
+const (
+	BOTHER  = 0x0000001F /* c_cflag: use c_ispeed/c_ospeed for arbitrary rates */
+	CBAUD   = 0x000000FF /* c_cflag: baud rate mask */
+	CIBAUD  = 0x00FF0000 /* c_cflag: input baud rate mask */
+	CMSPAR  = 0x40000000 /* c_cflag: mark or space (stick) parity */
+	CRTSCTS = 0x80000000 /* c_cflag: flow control */
+) // from <asm/termbits.h>
+
+const (
+	TCSBRK        = 0x2000741D /* _IO('t', 29): tcsendbreak, or tcdrain with a non-zero argument */
+	TCFLSH        = 0x2000741F /* _IO('t', 31): tcflush */
+	TIOCGRS485    = 0x542E
+	TIOCSRS485    = 0x542F
+	TIOCSERGETLSR = 0x5459 /* get line status register */
+) // from <asm/ioctls.h>
+
+// termios2 is struct termios from <asm/termbits.h>: powerpc has no termios2,
+// its termios already carries the speeds, with the line after the control
+// chars.
+type termios2 struct {
+	Iflag  uint32
+	Oflag  uint32
+	Cflag  uint32
+	Lflag  uint32
+	Cc     [19]uint8
+	Line   uint8
+	Ispeed uint32
+	Ospeed uint32
+}
+
+// TCGETS2 is TCGETS on powerpc.
+func TCGETS2() uintptr {
+	return sys.IOROf[termios2]('t', 19)
+}
+
+// TCSETS2 is TCSETS on powerpc.
+func TCSETS2() uintptr {
+	return sys.IOWOf[termios2]('t', 20)
+}
@@ -0,0 +1,203 @@
+//go:build linux
+// +build linux
+
+package bus
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// Parity of a serial line.
+type Parity uint8
+
+const (
+	PARITY_NONE Parity = iota
+	PARITY_ODD
+	PARITY_EVEN
+	PARITY_MARK
+	PARITY_SPACE
+)
+
+// FlowControl of a serial line.
+type FlowControl uint8
+
+const (
+	FLOW_NONE     FlowControl = iota
+	FLOW_HARDWARE             // RTS/CTS
+	FLOW_SOFTWARE             // XON/XOFF
+)
+
+var (
+	errInvalidBaud     = errors.New("invalid baud")
+	errInvalidDataBits = errors.New("invalid data bits")
+	errInvalidStopBits = errors.New("invalid stop bits")
+	errInvalidParity   = errors.New("invalid parity")
+	errInvalidFlow     = errors.New("invalid flow control")
+	errInvalidTimeout  = errors.New("invalid read timeout")
+)
+
+// SerialOptions configures a port opened by OpenSerialWithOptions.
+type SerialOptions struct {
+	Baud        uint        // any rate; rates without a Bxxx constant are set through BOTHER
+	DataBits    uint        // 5 to 8; 0 means 8
+	Parity      Parity      // PARITY_*
+	StopBits    uint        // 1 or 2; 0 means 1
+	FlowControl FlowControl // FLOW_*
+	// ReadTimeout bounds how long Read waits for data when no read deadline
+	// is set; 0 waits forever.
+	ReadTimeout time.Duration
+	// KeepModemLines leaves DTR and RTS alone instead of asserting them on open.
+	KeepModemLines bool
+}
+
+// apply sets up term for raw i/o as described by opts.
+func (opts *SerialOptions) apply(term *termios2) error {
+	if opts.Baud == 0 || uint64(opts.Baud) > 0xFFFFFFFF {
+		return errInvalidBaud
+	}
+	term.Cflag &^= CBAUD | CIBAUD
+	if b := getBaud(opts.Baud); b != 0 {
+		term.Cflag |= b
+	} else {
+		term.Cflag |= BOTHER
+	}
+	term.Ispeed = uint32(opts.Baud)
+	term.Ospeed = uint32(opts.Baud)
+
+	term.Cflag &^= syscall.CSIZE
+	switch opts.DataBits {
+	case 5:
+		term.Cflag |= syscall.CS5
+	case 6:
+		term.Cflag |= syscall.CS6
+	case 7:
+		term.Cflag |= syscall.CS7
+	case 0, 8:
+		term.Cflag |= syscall.CS8
+	default:
+		return errInvalidDataBits
+	}
+
+	switch opts.StopBits {
+	case 0, 1:
+		term.Cflag &^= syscall.CSTOPB
+	case 2:
+		term.Cflag |= syscall.CSTOPB
+	default:
+		return errInvalidStopBits
+	}
+
+	term.Iflag &^= syscall.INPCK
+	term.Cflag &^= syscall.PARENB | syscall.PARODD | CMSPAR
+	switch opts.Parity {
+	case PARITY_NONE:
+	case PARITY_ODD:
+		term.Cflag |= syscall.PARENB | syscall.PARODD
+	case PARITY_EVEN:
+		term.Cflag |= syscall.PARENB
+	case PARITY_MARK:
+		term.Cflag |= syscall.PARENB | syscall.PARODD | CMSPAR
+	case PARITY_SPACE:
+		term.Cflag |= syscall.PARENB | CMSPAR
+	default:
+		return errInvalidParity
+	}
+	if opts.Parity != PARITY_NONE {
+		term.Iflag |= syscall.INPCK
+	}
+
+	term.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON | syscall.IXOFF | syscall.IXANY
+	term.Cflag &^= CRTSCTS
+	switch opts.FlowControl {
+	case FLOW_NONE:
+	case FLOW_HARDWARE:
+		term.Cflag |= CRTSCTS
+	case FLOW_SOFTWARE:
+		term.Iflag |= syscall.IXON | syscall.IXOFF
+	default:
+		return errInvalidFlow
+	}
+
+	term.Cflag |= syscall.CLOCAL | syscall.CREAD
+	term.Oflag &^= syscall.OPOST
+	term.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.ECHOE | syscall.ECHONL | syscall.ISIG | syscall.IEXTEN
+
+	if opts.ReadTimeout < 0 {
+		return errInvalidTimeout
+	}
+	// timeouts are handled by the poller, a blocking read would return as
+	// soon as a byte arrived.
+	term.Cc[syscall.VMIN] = 1
+	term.Cc[syscall.VTIME] = 0
+	return nil
+}
+
+func getBaud(b uint) uint32 {
+	switch b {
+	case 50:
+		return syscall.B50
+	case 75:
+		return syscall.B75
+	case 110:
+		return syscall.B110
+	case 134:
+		return syscall.B134
+	case 150:
+		return syscall.B150
+	case 200:
+		return syscall.B200
+	case 300:
+		return syscall.B300
+	case 600:
+		return syscall.B600
+	case 1200:
+		return syscall.B1200
+	case 1800:
+		return syscall.B1800
+	case 2400:
+		return syscall.B2400
+	case 4800:
+		return syscall.B4800
+	case 9600:
+		return syscall.B9600
+	case 19200:
+		return syscall.B19200
+	case 38400:
+		return syscall.B38400
+	case 57600:
+		return syscall.B57600
+	case 115200:
+		return syscall.B115200
+	case 230400:
+		return syscall.B230400
+	case 460800:
+		return syscall.B460800
+	case 500000:
+		return syscall.B500000
+	case 576000:
+		return syscall.B576000
+	case 921600:
+		return syscall.B921600
+	case 1000000:
+		return syscall.B1000000
+	case 1152000:
+		return syscall.B1152000
+	case 1500000:
+		return syscall.B1500000
+	case 2000000:
+		return syscall.B2000000
+	case 2500000:
+		return syscall.B2500000
+	case 3000000:
+		return syscall.B3000000
+	case 3500000:
+		return syscall.B3500000
+	case 4000000:
+		return syscall.B4000000
+	default:
+	}
+	return 0
+}
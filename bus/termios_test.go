@@ -0,0 +1,65 @@
+//go:build linux
+// +build linux
+
+package bus
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTermios(t *testing.T) {
+	term := termios2{Cflag: syscall.B9600 | syscall.CS7 | syscall.PARENB | CRTSCTS, Lflag: syscall.ICANON | syscall.ECHO}
+	opts := SerialOptions{Baud: 250000, Parity: PARITY_MARK, StopBits: 2, ReadTimeout: 1500 * time.Millisecond}
+	if err := opts.apply(&term); err != nil {
+		t.Fatal(err)
+	}
+	if term.Cflag&CBAUD != BOTHER || term.Ispeed != 250000 || term.Ospeed != 250000 {
+		t.Errorf("baud mismatch: cflag 0%o, ispeed %d, ospeed %d", term.Cflag, term.Ispeed, term.Ospeed)
+	}
+	if term.Cflag&syscall.CSIZE != syscall.CS8 {
+		t.Errorf("data bits mismatch: cflag 0%o", term.Cflag)
+	}
+	if term.Cflag&(syscall.PARENB|syscall.PARODD|CMSPAR) != syscall.PARENB|syscall.PARODD|CMSPAR || term.Iflag&syscall.INPCK == 0 {
+		t.Errorf("parity mismatch: cflag 0%o, iflag 0%o", term.Cflag, term.Iflag)
+	}
+	if term.Cflag&syscall.CSTOPB == 0 {
+		t.Errorf("stop bits mismatch: cflag 0%o", term.Cflag)
+	}
+	if term.Cflag&CRTSCTS != 0 || term.Iflag&(syscall.IXON|syscall.IXOFF) != 0 {
+		t.Errorf("flow control mismatch: cflag 0%o, iflag 0%o", term.Cflag, term.Iflag)
+	}
+	if term.Lflag&(syscall.ICANON|syscall.ECHO) != 0 {
+		t.Errorf("raw mode mismatch: lflag 0%o", term.Lflag)
+	}
+	if term.Cc[syscall.VMIN] != 1 || term.Cc[syscall.VTIME] != 0 {
+		t.Errorf("timeout mismatch: vmin %d, vtime %d", term.Cc[syscall.VMIN], term.Cc[syscall.VTIME])
+	}
+
+	opts = SerialOptions{Baud: 115200, DataBits: 7, Parity: PARITY_EVEN, FlowControl: FLOW_SOFTWARE}
+	if err := opts.apply(&term); err != nil {
+		t.Fatal(err)
+	}
+	if term.Cflag&CBAUD != syscall.B115200 {
+		t.Errorf("baud mismatch: cflag 0%o", term.Cflag)
+	}
+	if term.Cflag&(syscall.PARENB|syscall.PARODD|CMSPAR) != syscall.PARENB || term.Cflag&syscall.CSTOPB != 0 {
+		t.Errorf("framing mismatch: cflag 0%o", term.Cflag)
+	}
+	if term.Iflag&(syscall.IXON|syscall.IXOFF) != syscall.IXON|syscall.IXOFF {
+		t.Errorf("flow control mismatch: iflag 0%o", term.Iflag)
+	}
+
+	for _, opts := range []SerialOptions{
+		{},
+		{Baud: 9600, DataBits: 9},
+		{Baud: 9600, StopBits: 3},
+		{Baud: 9600, Parity: PARITY_SPACE + 1},
+		{Baud: 9600, ReadTimeout: -time.Second},
+	} {
+		if err := opts.apply(&term); err == nil {
+			t.Errorf("invalid options accepted: %+v", opts)
+		}
+	}
+}
@@ -28,45 +28,46 @@ package ds1307
 
 import (
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/zyxar/berry/bus"
 )
 
 type Clock struct {
-	h *bus.I2C
-	m *sync.Mutex
-}
-
-var clockTable map[uint64]*Clock
-var mutex = sync.Mutex{} // protect table
-
-func init() {
-	clockTable = make(map[uint64]*Clock)
+	b *bus.I2CBus
+	h bus.I2CConn
 }
 
+// New opens the clock at addr on i2c adapter dev. The adapter is shared with
+// other drivers in the process; Close releases it.
 func New(addr, dev uint) (*Clock, error) {
-	defer mutex.Unlock()
-	mutex.Lock()
-	if k, ok := clockTable[addrKey(addr, dev)]; ok {
-		return k, nil
+	b, err := bus.OpenI2CBus(dev)
+	if err != nil {
+		return nil, err
 	}
-	i, err := bus.NewI2C(addr, dev)
+	h, err := b.Device(addr, nil)
 	if err != nil {
+		b.Close()
 		return nil, err
 	}
-	r := &Clock{i, &sync.Mutex{}}
-	clockTable[addrKey(addr, dev)] = r
-	return r, nil
+	return &Clock{b, h}, nil
+}
+
+// NewClock returns a clock talking through conn, which the caller owns.
+func NewClock(conn bus.I2CConn) *Clock {
+	return &Clock{h: conn}
+}
+
+func (this *Clock) Close() error {
+	if this.b == nil {
+		return nil
+	}
+	return this.b.Close()
 }
 
 func (this *Clock) Get() (t time.Time, err error) {
-	defer this.m.Unlock()
-	this.m.Lock()
-	this.h.Write(0)
 	b := make([]byte, 7)
-	if err = this.h.Read(b); err != nil {
+	if err = this.h.Tx([]byte{0}, b); err != nil {
 		return
 	}
 	// A few of these need masks because certain bits are control bits
@@ -91,8 +92,6 @@ func (this *Clock) Get() (t time.Time, err error) {
 }
 
 func (this *Clock) Set(now time.Time) error {
-	defer this.m.Unlock()
-	this.m.Lock()
 	return this.h.Write(0,
 		decToBcd(byte(now.Second())),
 		decToBcd(byte(now.Minute())),
@@ -110,7 +109,3 @@ func decToBcd(val byte) byte {
 func bcdToDec(val byte) byte {
 	return ((val / 16 * 10) + (val % 16))
 }
-
-func addrKey(addr, dev uint) uint64 {
-	return (uint64(addr) << 32) | uint64(dev)
-}
@@ -0,0 +1,40 @@
+package ds1307
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zyxar/berry/bus/bustest"
+)
+
+func TestGet(t *testing.T) {
+	ops, err := bustest.Load("testdata/get.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := bustest.NewI2C(ops...)
+	now, err := NewClock(m).Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if now.Year() != 2016 || now.Month() != time.July || now.Day() != 31 ||
+		now.Hour() != 13 || now.Minute() != 45 || now.Second() != 9 {
+		t.Errorf("time mismatch: %v", now)
+	}
+	if err = m.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	m := bustest.NewI2C(bustest.Op{
+		Kind: bustest.WRITE,
+		W:    []byte{0x00, 0x09, 0x45, 0x13, 0x00, 0x31, 0x07, 0x16},
+	})
+	if err := NewClock(m).Set(time.Date(2016, time.July, 31, 13, 45, 9, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Verify(); err != nil {
+		t.Error(err)
+	}
+}
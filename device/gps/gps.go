@@ -0,0 +1,184 @@
+package gps
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Fix is the state of the receiver at the end of an epoch.
+type Fix struct {
+	Time       time.Time // UTC, zero until the receiver reports the date
+	Valid      bool
+	Quality    int // from GGA
+	FixType    int // from GSA: 1 none, 2 2D, 3 3D
+	Latitude   float64
+	Longitude  float64
+	Altitude   float64 // above mean sea level, meters
+	Speed      float64 // over ground, knots
+	Course     float64 // over ground, degrees true
+	Satellites int     // used in the fix
+	InView     int
+	PDOP       float64
+	HDOP       float64
+	VDOP       float64
+	Received   time.Time // local time the epoch ended
+	PPS        time.Time // local time of the pulse starting Time's second, if any
+}
+
+// Receiver reads sentences from a GPS and publishes a Fix once per epoch.
+// The last sentence of an epoch is learned from the first change of time.
+type Receiver struct {
+	C <-chan Fix
+
+	c    chan Fix
+	r    *bufio.Reader
+	pps  *PPS
+	m    sync.Mutex
+	fix  Fix
+	last Fix // last published
+	date time.Time
+	tod  time.Duration
+	seen bool // whether tod belongs to the current epoch
+	prev string
+	end  string
+}
+
+// NewReceiver reads sentences from r, such as a *bus.Serial.
+func NewReceiver(r io.Reader) *Receiver {
+	c := make(chan Fix, 1)
+	return &Receiver{C: c, c: c, r: bufio.NewReader(r)}
+}
+
+// UsePPS disciplines published fixes and Now with the pulses seen by p.
+func (this *Receiver) UsePPS(p *PPS) {
+	this.m.Lock()
+	this.pps = p
+	this.m.Unlock()
+}
+
+// Run reads sentences until r fails, skipping those that do not parse.
+// Timeouts of r are ignored; a sentence they split is completed by the next
+// read.
+func (this *Receiver) Run() error {
+	var pending string
+	for {
+		line, err := this.r.ReadString('\n')
+		if err != nil {
+			if os.IsTimeout(err) {
+				pending += line
+				continue
+			}
+			return err
+		}
+		line, pending = pending+line, ""
+		if s, err := Parse(line); err == nil {
+			this.Update(s)
+		}
+	}
+}
+
+// Update folds s into the current epoch, publishing it when it ends.
+func (this *Receiver) Update(s Sentence) {
+	this.m.Lock()
+	defer this.m.Unlock()
+	switch s := s.(type) {
+	case *GGA:
+		this.clock(s.Time)
+		this.fix.Quality = s.Quality
+		this.fix.Valid = s.Quality > 0
+		this.fix.Latitude, this.fix.Longitude = s.Latitude, s.Longitude
+		this.fix.Altitude = s.Altitude
+		this.fix.Satellites = s.Satellites
+		this.fix.HDOP = s.HDOP
+	case *RMC:
+		this.stamp(s.Time)
+		this.fix.Valid = s.Valid
+		this.fix.Latitude, this.fix.Longitude = s.Latitude, s.Longitude
+		this.fix.Speed, this.fix.Course = s.Speed, s.Course
+	case *GSA:
+		this.fix.FixType = s.FixType
+		this.fix.PDOP, this.fix.HDOP, this.fix.VDOP = s.PDOP, s.HDOP, s.VDOP
+	case *GSV:
+		this.fix.InView = s.InView
+	case *VTG:
+		this.fix.Speed, this.fix.Course = s.SpeedKnots, s.TrueCourse
+	case *ZDA:
+		this.stamp(s.Time)
+	}
+	typ := s.header().Type
+	this.prev = typ
+	if g, ok := s.(*GSV); ok && g.Number < g.Total {
+		return
+	}
+	if typ == this.end {
+		this.publish()
+	}
+}
+
+// stamp is clock for sentences carrying the date as well.
+func (this *Receiver) stamp(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	date := t.Truncate(24 * time.Hour)
+	this.clock(t.Sub(date))
+	this.date = date
+}
+
+// clock starts a new epoch when tod differs from the current one.
+func (this *Receiver) clock(tod time.Duration) {
+	if this.seen && tod != this.tod {
+		if this.end == "" {
+			this.end = this.prev
+			this.publish()
+		}
+		this.seen = false
+	}
+	if !this.seen {
+		this.seen = true
+		this.tod = tod
+	}
+}
+
+func (this *Receiver) publish() {
+	fix := this.fix
+	fix.Received = time.Now()
+	if !this.date.IsZero() && this.seen {
+		fix.Time = this.date.Add(this.tod)
+	}
+	if this.pps != nil {
+		// the pulse precedes the sentences of its second
+		if edge := this.pps.Last(); fix.Received.Sub(edge) < time.Second {
+			fix.PPS = edge
+		}
+	}
+	this.last = fix
+	select {
+	case <-this.c: // drop the stale fix
+	default:
+	}
+	this.c <- fix
+}
+
+// Last returns the last published fix.
+func (this *Receiver) Last() Fix {
+	this.m.Lock()
+	defer this.m.Unlock()
+	return this.last
+}
+
+// Now estimates the current UTC time from the last fix, to the pulse edge if
+// it was disciplined by PPS, else to the arrival of the sentences.
+func (this *Receiver) Now() (t time.Time, ok bool) {
+	fix := this.Last()
+	if fix.Time.IsZero() {
+		return
+	}
+	if !fix.PPS.IsZero() {
+		return fix.Time.Truncate(time.Second).Add(time.Since(fix.PPS)), true
+	}
+	return fix.Time.Add(time.Since(fix.Received)), true
+}
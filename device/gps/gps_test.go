@@ -0,0 +1,137 @@
+package gps
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReceiver(t *testing.T) {
+	f, err := os.Open("testdata/ublox.nmea")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := NewReceiver(nil)
+	var fixes []Fix
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if s, err := Parse(scanner.Text()); err == nil {
+			r.Update(s)
+		}
+		select {
+		case fix := <-r.C:
+			fixes = append(fixes, fix)
+		default:
+		}
+	}
+	if len(fixes) != 3 {
+		t.Fatalf("fix count mismatch: %d", len(fixes))
+	}
+	for i, fix := range fixes {
+		want := time.Date(2002, 12, 9, 8, 35, 59+i, 0, time.UTC)
+		if !fix.Time.Equal(want) {
+			t.Errorf("fix %d time mismatch: %v", i, fix.Time)
+		}
+		if !fix.Valid || fix.FixType != 3 || fix.Satellites != 8 || fix.InView != 10 || fix.Altitude != 499.6 {
+			t.Errorf("fix %d mismatch: %+v", i, fix)
+		}
+	}
+	if !near(fixes[0].Latitude, 47+17.11437/60) || !near(fixes[0].Longitude, 8+33.91522/60) {
+		t.Errorf("position mismatch: %v, %v", fixes[0].Latitude, fixes[0].Longitude)
+	}
+	if r.Last() != fixes[2] {
+		t.Error("last fix mismatch")
+	}
+	now, ok := r.Now()
+	if !ok || now.Sub(fixes[2].Time) < 0 || now.Sub(fixes[2].Time) > time.Second {
+		t.Errorf("now mismatch: %v", now)
+	}
+}
+
+// chunks returns each chunk in turn, failing with a timeout after those
+// ending mid-sentence.
+type chunks struct {
+	data    []string
+	timeout bool
+}
+
+func (this *chunks) Read(p []byte) (int, error) {
+	if this.timeout {
+		this.timeout = false
+		return 0, os.ErrDeadlineExceeded
+	}
+	if len(this.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, this.data[0])
+	this.timeout = this.data[0][n-1] != '\n'
+	this.data = this.data[1:]
+	return n, nil
+}
+
+func TestReceiverTimeout(t *testing.T) {
+	r := NewReceiver(&chunks{data: []string{
+		"$GPZDA,201530.00,04,07,2002,-0",
+		"5,30*4B\r\n$GPZDA,201531.",
+		"00,04,07,2002,-05,30*4A\r\n",
+	}})
+	if err := r.Run(); err != io.EOF {
+		t.Fatalf("%v expected, got %v", io.EOF, err)
+	}
+	// only both sentences make an epoch end, learned from the change of time
+	select {
+	case fix := <-r.C:
+		if !fix.Time.Equal(time.Date(2002, 7, 4, 20, 15, 31, 0, time.UTC)) {
+			t.Errorf("fix mismatch: %+v", fix)
+		}
+	default:
+		t.Error("split sentence lost")
+	}
+}
+
+type levelPin int32
+
+func (this *levelPin) DigitalRead() uint8 {
+	return uint8(atomic.LoadInt32((*int32)(this)))
+}
+
+func TestPPS(t *testing.T) {
+	var pin levelPin
+	p := NewPPS(&pin, time.Millisecond)
+	defer p.Close()
+	time.Sleep(5 * time.Millisecond)
+	if !p.Last().IsZero() {
+		t.Error("edge without pulse")
+	}
+	before := time.Now()
+	atomic.StoreInt32((*int32)(&pin), 1)
+	time.Sleep(20 * time.Millisecond)
+	edge := p.Last()
+	if edge.Before(before) || edge.Sub(before) > 20*time.Millisecond {
+		t.Errorf("edge time mismatch: %v", edge.Sub(before))
+	}
+	r := NewReceiver(nil)
+	r.UsePPS(p)
+	for _, line := range []string{
+		"$GPZDA,083559.00,09,12,2002,00,00*6E",
+		"$GPZDA,083600.00,09,12,2002,00,00*61",
+	} {
+		s, err := Parse(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Update(s)
+	}
+	fix := <-r.C
+	if !fix.PPS.Equal(edge) {
+		t.Errorf("pps not applied: %+v", fix)
+	}
+	now, _ := r.Now()
+	if d := now.Sub(fix.Time) - time.Since(edge); d < -time.Millisecond || d > time.Millisecond {
+		t.Errorf("now not disciplined: %v", d)
+	}
+}
@@ -0,0 +1,389 @@
+// Package gps reads NMEA 0183 sentences from GPS receivers.
+package gps
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrChecksum    = errors.New("gps: checksum mismatch")
+	ErrFormat      = errors.New("gps: malformed sentence")
+	ErrUnsupported = errors.New("gps: unsupported sentence")
+)
+
+// Header identifies a sentence, e.g. talker "GP" and type "GGA" for $GPGGA.
+type Header struct {
+	Talker string
+	Type   string
+}
+
+func (this *Header) header() *Header { return this }
+
+// Sentence is one of *GGA, *RMC, *GSA, *GSV, *VTG and *ZDA.
+type Sentence interface {
+	header() *Header
+}
+
+// GGA is the fix data of a sentence like $GPGGA.
+type GGA struct {
+	Header
+	Time       time.Duration // UTC time of day
+	Latitude   float64       // degrees, negative south
+	Longitude  float64       // degrees, negative west
+	Quality    int           // 0 invalid, 1 GPS, 2 DGPS, 4 RTK fixed, 5 RTK float, 6 estimated
+	Satellites int           // used in the fix
+	HDOP       float64
+	Altitude   float64 // above mean sea level, meters
+	Separation float64 // of the geoid above the ellipsoid, meters
+}
+
+// RMC is the recommended minimum data of a sentence like $GPRMC.
+type RMC struct {
+	Header
+	Time      time.Time // UTC
+	Valid     bool
+	Latitude  float64
+	Longitude float64
+	Speed     float64 // over ground, knots
+	Course    float64 // over ground, degrees true
+	Variation float64 // magnetic, degrees, negative west
+	Mode      byte    // A autonomous, D differential, E estimated, N not valid; 0 before NMEA 2.3
+}
+
+// GSA is the DOP and active satellites of a sentence like $GPGSA.
+type GSA struct {
+	Header
+	Auto    bool // automatic 2D/3D selection
+	FixType int  // 1 none, 2 2D, 3 3D
+	PRNs    []int
+	PDOP    float64
+	HDOP    float64
+	VDOP    float64
+}
+
+// Satellite is one satellite in view.
+type Satellite struct {
+	PRN       int
+	Elevation int // degrees
+	Azimuth   int // degrees true
+	SNR       int // dB-Hz, -1 if not tracking
+}
+
+// GSV is one of the messages of a sentence like $GPGSV listing satellites in view.
+type GSV struct {
+	Header
+	Total      int // messages in this cycle
+	Number     int // of this message, from 1
+	InView     int
+	Satellites []Satellite
+}
+
+// VTG is the course and speed of a sentence like $GPVTG.
+type VTG struct {
+	Header
+	TrueCourse     float64
+	MagneticCourse float64
+	SpeedKnots     float64
+	SpeedKmh       float64
+	Mode           byte
+}
+
+// ZDA is the time and date of a sentence like $GPZDA.
+type ZDA struct {
+	Header
+	Time time.Time     // UTC
+	Zone time.Duration // local zone offset
+}
+
+// Checksum returns the XOR of the bytes of s, which excludes '$' and '*'.
+func Checksum(s string) (sum byte) {
+	for i := 0; i < len(s); i++ {
+		sum ^= s[i]
+	}
+	return
+}
+
+// Parse parses and validates a sentence such as
+// "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47".
+func Parse(line string) (s Sentence, err error) {
+	line = strings.TrimSpace(line)
+	star := strings.LastIndexByte(line, '*')
+	if len(line) < 7 || line[0] != '$' || star < 0 || star+3 != len(line) {
+		err = ErrFormat
+		return
+	}
+	sum, err := strconv.ParseUint(line[star+1:], 16, 8)
+	if err != nil {
+		err = ErrFormat
+		return
+	}
+	if byte(sum) != Checksum(line[1:star]) {
+		err = ErrChecksum
+		return
+	}
+	fields := strings.Split(line[1:star], ",")
+	if len(fields[0]) != 5 || fields[0][0] == 'P' {
+		err = ErrUnsupported
+		return
+	}
+	h := Header{Talker: fields[0][:2], Type: fields[0][2:]}
+	p := &parser{fields: fields[1:]}
+	switch h.Type {
+	case "GGA":
+		s = p.gga(h)
+	case "RMC":
+		s = p.rmc(h)
+	case "GSA":
+		s = p.gsa(h)
+	case "GSV":
+		s = p.gsv(h)
+	case "VTG":
+		s = p.vtg(h)
+	case "ZDA":
+		s = p.zda(h)
+	default:
+		err = ErrUnsupported
+		return
+	}
+	if p.err != nil {
+		s, err = nil, p.err
+	}
+	return
+}
+
+// parser reads fields by index; empty fields read as zero, and the first
+// malformed one sets err.
+type parser struct {
+	fields []string
+	err    error
+}
+
+func (this *parser) field(i int) string {
+	if i < len(this.fields) {
+		return this.fields[i]
+	}
+	return ""
+}
+
+func (this *parser) fail() {
+	if this.err == nil {
+		this.err = ErrFormat
+	}
+}
+
+func (this *parser) need(n int) {
+	if len(this.fields) < n {
+		this.fail()
+	}
+}
+
+func (this *parser) float(i int) float64 {
+	s := this.field(i)
+	if s == "" {
+		return 0
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		this.fail()
+	}
+	return v
+}
+
+func (this *parser) int(i int) int {
+	s := this.field(i)
+	if s == "" {
+		return 0
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		this.fail()
+	}
+	return v
+}
+
+func (this *parser) byte(i int) byte {
+	if s := this.field(i); s != "" {
+		return s[0]
+	}
+	return 0
+}
+
+// degrees parses ddmm.mmmm (or dddmm.mmmm) followed by a hemisphere field.
+func (this *parser) degrees(i int, negative byte) float64 {
+	s := this.field(i)
+	if s == "" {
+		return 0
+	}
+	dot := strings.IndexByte(s, '.')
+	if dot < 0 {
+		dot = len(s)
+	}
+	if dot < 3 {
+		this.fail()
+		return 0
+	}
+	d, err1 := strconv.Atoi(s[:dot-2])
+	m, err2 := strconv.ParseFloat(s[dot-2:], 64)
+	if err1 != nil || err2 != nil {
+		this.fail()
+		return 0
+	}
+	v := float64(d) + m/60
+	if this.byte(i+1) == negative {
+		v = -v
+	}
+	return v
+}
+
+// clock parses hhmmss(.sss) as the time since midnight.
+func (this *parser) clock(i int) time.Duration {
+	s := this.field(i)
+	if s == "" {
+		return 0
+	}
+	if len(s) < 6 {
+		this.fail()
+		return 0
+	}
+	h, err1 := strconv.Atoi(s[0:2])
+	m, err2 := strconv.Atoi(s[2:4])
+	sec, err3 := strconv.ParseFloat(s[4:], 64)
+	if err1 != nil || err2 != nil || err3 != nil || h > 23 || m > 59 || sec >= 61 {
+		this.fail()
+		return 0
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec*1e9+0.5)
+}
+
+// date parses ddmmyy, taking years before 80 as 20yy.
+func (this *parser) date(i int) (t time.Time) {
+	s := this.field(i)
+	if s == "" {
+		return
+	}
+	if len(s) != 6 {
+		this.fail()
+		return
+	}
+	d, err1 := strconv.Atoi(s[0:2])
+	m, err2 := strconv.Atoi(s[2:4])
+	y, err3 := strconv.Atoi(s[4:6])
+	if err1 != nil || err2 != nil || err3 != nil {
+		this.fail()
+		return
+	}
+	if y < 80 {
+		y += 2000
+	} else {
+		y += 1900
+	}
+	return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+}
+
+func (this *parser) gga(h Header) *GGA {
+	this.need(11)
+	return &GGA{
+		Header:     h,
+		Time:       this.clock(0),
+		Latitude:   this.degrees(1, 'S'),
+		Longitude:  this.degrees(3, 'W'),
+		Quality:    this.int(5),
+		Satellites: this.int(6),
+		HDOP:       this.float(7),
+		Altitude:   this.float(8),
+		Separation: this.float(10),
+	}
+}
+
+func (this *parser) rmc(h Header) *RMC {
+	this.need(9)
+	s := &RMC{
+		Header:    h,
+		Valid:     this.byte(1) == 'A',
+		Latitude:  this.degrees(2, 'S'),
+		Longitude: this.degrees(4, 'W'),
+		Speed:     this.float(6),
+		Course:    this.float(7),
+		Variation: this.float(9),
+		Mode:      this.byte(11),
+	}
+	if this.byte(10) == 'W' {
+		s.Variation = -s.Variation
+	}
+	if date := this.date(8); !date.IsZero() {
+		s.Time = date.Add(this.clock(0))
+	}
+	return s
+}
+
+func (this *parser) gsa(h Header) *GSA {
+	this.need(17)
+	s := &GSA{
+		Header:  h,
+		Auto:    this.byte(0) == 'A',
+		FixType: this.int(1),
+		PDOP:    this.float(14),
+		HDOP:    this.float(15),
+		VDOP:    this.float(16),
+	}
+	for i := 2; i < 14; i++ {
+		if this.field(i) != "" {
+			s.PRNs = append(s.PRNs, this.int(i))
+		}
+	}
+	return s
+}
+
+func (this *parser) gsv(h Header) *GSV {
+	this.need(3)
+	s := &GSV{
+		Header: h,
+		Total:  this.int(0),
+		Number: this.int(1),
+		InView: this.int(2),
+	}
+	for i := 3; i+3 < len(this.fields); i += 4 {
+		sat := Satellite{
+			PRN:       this.int(i),
+			Elevation: this.int(i + 1),
+			Azimuth:   this.int(i + 2),
+			SNR:       -1,
+		}
+		if this.field(i+3) != "" {
+			sat.SNR = this.int(i + 3)
+		}
+		s.Satellites = append(s.Satellites, sat)
+	}
+	return s
+}
+
+func (this *parser) vtg(h Header) *VTG {
+	this.need(8)
+	return &VTG{
+		Header:         h,
+		TrueCourse:     this.float(0),
+		MagneticCourse: this.float(2),
+		SpeedKnots:     this.float(4),
+		SpeedKmh:       this.float(6),
+		Mode:           this.byte(8),
+	}
+}
+
+func (this *parser) zda(h Header) *ZDA {
+	this.need(6)
+	s := &ZDA{Header: h}
+	y, m, d := this.int(3), this.int(2), this.int(1)
+	if y != 0 {
+		s.Time = time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC).Add(this.clock(0))
+	}
+	zone := time.Duration(this.int(4)) * time.Hour
+	minutes := time.Duration(this.int(5)) * time.Minute
+	if zone < 0 {
+		minutes = -minutes
+	}
+	s.Zone = zone + minutes
+	return s
+}
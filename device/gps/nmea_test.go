@@ -0,0 +1,113 @@
+package gps
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse("$GPGGA,123519,4807.038,N,01131.000,W,1,08,0.9,545.4,M,46.9,M,,*55\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gga, ok := s.(*GGA)
+	if !ok || gga.Talker != "GP" || gga.Type != "GGA" {
+		t.Fatalf("GGA expected: %#v", s)
+	}
+	if gga.Time != 12*time.Hour+35*time.Minute+19*time.Second || !near(gga.Latitude, 48.1173) ||
+		!near(gga.Longitude, -11.516666666) || gga.Quality != 1 || gga.Satellites != 8 ||
+		gga.HDOP != 0.9 || gga.Altitude != 545.4 || gga.Separation != 46.9 {
+		t.Errorf("GGA mismatch: %+v", gga)
+	}
+
+	s, err = Parse("$GNRMC,225446.33,A,4916.45,S,12311.12,W,000.5,054.7,191194,020.3,E,A*28")
+	rmc, ok := s.(*RMC)
+	if err != nil || !ok {
+		t.Fatalf("RMC expected: %v", err)
+	}
+	if rmc.Talker != "GN" || !rmc.Valid || !near(rmc.Latitude, -49.274166666) ||
+		!rmc.Time.Equal(time.Date(1994, 11, 19, 22, 54, 46, 330000000, time.UTC)) ||
+		rmc.Speed != 0.5 || rmc.Course != 54.7 || rmc.Variation != 20.3 || rmc.Mode != 'A' {
+		t.Errorf("RMC mismatch: %+v", rmc)
+	}
+
+	s, err = Parse("$GPGSV,3,3,11,26,82,187,47,28,43,056,,30,,,*77")
+	gsv, ok := s.(*GSV)
+	if err != nil || !ok {
+		t.Fatalf("GSV expected: %v", err)
+	}
+	if gsv.Total != 3 || gsv.Number != 3 || gsv.InView != 11 || len(gsv.Satellites) != 3 ||
+		gsv.Satellites[0] != (Satellite{26, 82, 187, 47}) || gsv.Satellites[1].SNR != -1 || gsv.Satellites[2].PRN != 30 {
+		t.Errorf("GSV mismatch: %+v", gsv)
+	}
+
+	s, err = Parse("$GPZDA,201530.00,04,07,2002,-05,30*4B")
+	zda, ok := s.(*ZDA)
+	if err != nil || !ok {
+		t.Fatalf("ZDA expected: %v", err)
+	}
+	if !zda.Time.Equal(time.Date(2002, 7, 4, 20, 15, 30, 0, time.UTC)) || zda.Zone != -5*time.Hour-30*time.Minute {
+		t.Errorf("ZDA mismatch: %+v", zda)
+	}
+
+	for line, want := range map[string]error{
+		"$GPGGA,123519,4807.038,N,01131.000,W,1,08,0.9,545.4,M,46.9,M,,*54": ErrChecksum,
+		"GPGGA,123519,4807.038,N,01131.000,W,1,08,0.9,545.4,M,46.9,M,,*55":  ErrFormat,
+		"$GPGGA,123519,4807.038,N,01131.000,W,1,08,0.9,545.4,M,46.9,M,,":    ErrFormat,
+		"$GPGGA,1235,4807.038,N,01131.000,W,1,08,0.9,545.4,M,46.9,M,,*5D":   ErrFormat,
+		"$GPVTG,77.52,T*2F":              ErrFormat,
+		"$GPGLL,4916.45,N,12311.12,W*71": ErrUnsupported,
+		"$PUBX,00*33":                    ErrUnsupported,
+	} {
+		if _, err = Parse(line); err != want {
+			t.Errorf("%q: %v expected, got %v", line, want, err)
+		}
+	}
+}
+
+func TestParseLog(t *testing.T) {
+	f, err := os.Open("testdata/ublox.nmea")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	counts := make(map[string]int)
+	var failed int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s, err := Parse(scanner.Text())
+		if err != nil {
+			failed++
+			continue
+		}
+		counts[s.header().Type]++
+		if gsa, ok := s.(*GSA); ok {
+			if !gsa.Auto || gsa.FixType != 3 || len(gsa.PRNs) != 8 || gsa.PDOP != 1.94 || gsa.VDOP != 1.66 {
+				t.Errorf("GSA mismatch: %+v", gsa)
+			}
+		}
+		if vtg, ok := s.(*VTG); ok {
+			if vtg.TrueCourse != 77.52 || vtg.SpeedKnots != 0.004 || vtg.SpeedKmh != 0.008 || vtg.Mode != 'A' {
+				t.Errorf("VTG mismatch: %+v", vtg)
+			}
+		}
+	}
+	for _, typ := range []string{"RMC", "VTG", "GGA", "GSA", "ZDA"} {
+		if counts[typ] != 3 {
+			t.Errorf("%s count mismatch: %d", typ, counts[typ])
+		}
+	}
+	if counts["GSV"] != 9 {
+		t.Errorf("GSV count mismatch: %d", counts["GSV"])
+	}
+	if failed != 6 { // TXT, no '$', 3 GLL, bad checksum
+		t.Errorf("failed count mismatch: %d", failed)
+	}
+}
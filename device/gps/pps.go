@@ -0,0 +1,68 @@
+package gps
+
+import (
+	"sync"
+	"time"
+)
+
+// Pin is a digital input, such as core.Pin.
+type Pin interface {
+	DigitalRead() uint8
+}
+
+// PPS watches the pulse-per-second output of a receiver on a pin. Edges are
+// found by polling, so their timestamps are only as precise as the interval.
+type PPS struct {
+	pin      Pin
+	interval time.Duration
+	m        sync.Mutex
+	last     time.Time
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewPPS polls pin every interval for rising edges until Close.
+func NewPPS(pin Pin, interval time.Duration) *PPS {
+	p := &PPS{
+		pin:      pin,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.poll()
+	return p
+}
+
+func (this *PPS) poll() {
+	defer close(this.done)
+	level := this.pin.DigitalRead()
+	ticker := time.NewTicker(this.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-this.stop:
+			return
+		case <-ticker.C:
+			v := this.pin.DigitalRead()
+			if v != 0 && level == 0 {
+				this.m.Lock()
+				this.last = time.Now()
+				this.m.Unlock()
+			}
+			level = v
+		}
+	}
+}
+
+// Last returns the local time of the last rising edge.
+func (this *PPS) Last() time.Time {
+	this.m.Lock()
+	defer this.m.Unlock()
+	return this.last
+}
+
+// Close stops polling.
+func (this *PPS) Close() {
+	close(this.stop)
+	<-this.done
+}
@@ -0,0 +1,337 @@
+// Package hat reads and writes the ID EEPROM of Raspberry Pi HATs.
+package hat
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ref: https://github.com/raspberrypi/hats/blob/master/eeprom-format.md
+
+const (
+	SIGNATURE = "R-Pi"
+	VERSION   = 0x01
+
+	HEADER_SIZE = 12
+	ATOM_HEADER = 8 // type, count and dlen
+	CRC_SIZE    = 2
+
+	ATOM_INVALID   = 0x0000
+	ATOM_VENDOR    = 0x0001
+	ATOM_GPIO_MAP  = 0x0002
+	ATOM_DT_BLOB   = 0x0003
+	ATOM_CUSTOM    = 0x0004
+	ATOM_INVALID_2 = 0xFFFF
+
+	GPIO_COUNT = 28
+)
+
+const (
+	GPIO_INPUT  = 0
+	GPIO_OUTPUT = 1
+	GPIO_ALT0   = 4
+	GPIO_ALT1   = 5
+	GPIO_ALT2   = 6
+	GPIO_ALT3   = 7
+	GPIO_ALT4   = 3
+	GPIO_ALT5   = 2
+)
+
+const (
+	PULL_DEFAULT = 0
+	PULL_UP      = 1
+	PULL_DOWN    = 2
+	PULL_NONE    = 3
+)
+
+var (
+	ErrSignature = errors.New("hat: bad eeprom signature")
+	ErrVersion   = errors.New("hat: unsupported eeprom version")
+	ErrTruncated = errors.New("hat: eeprom image truncated")
+	ErrAtomType  = errors.New("hat: invalid atom type")
+	ErrTooLong   = errors.New("hat: string longer than 255 bytes")
+)
+
+// CRCError reports an atom whose CRC does not match.
+type CRCError struct {
+	Index int
+	Type  uint16
+}
+
+func (this *CRCError) Error() string {
+	return fmt.Sprintf("hat: crc mismatch in atom %d (type 0x%04x)", this.Index, this.Type)
+}
+
+// UUID is 128 bits in the usual big-endian order; the EEPROM stores it
+// as little endian.
+type UUID [16]byte
+
+func (this UUID) String() string {
+	b := this[:]
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// ParseUUID parses the xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx form.
+func ParseUUID(s string) (u UUID, err error) {
+	if len(s) != 36 {
+		err = fmt.Errorf("hat: invalid uuid %q", s)
+		return
+	}
+	var n int
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		dash := i == 8 || i == 13 || i == 18 || i == 23
+		if dash != (c == '-') {
+			err = fmt.Errorf("hat: invalid uuid %q", s)
+			return
+		}
+		if dash {
+			continue
+		}
+		var v byte
+		switch {
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			v = c - 'A' + 10
+		default:
+			err = fmt.Errorf("hat: invalid uuid %q", s)
+			return
+		}
+		u[n/2] |= v << uint(4*(1-n%2))
+		n++
+	}
+	return
+}
+
+// VendorInfo is the vendor info atom.
+type VendorInfo struct {
+	UUID       UUID
+	ProductID  uint16
+	ProductVer uint16
+	Vendor     string
+	Product    string
+}
+
+// GPIO is the setting of a bank 0 pin.
+type GPIO struct {
+	Used     bool
+	Function uint8 // GPIO_INPUT, GPIO_OUTPUT or GPIO_ALTn
+	Pull     uint8 // PULL_*
+}
+
+// GPIOMap is the GPIO map atom.
+type GPIOMap struct {
+	Drive      uint8 // 0 for default, 1-8 for 2-16 mA
+	Slew       uint8 // 0 default, 1 rate limiting, 2 no limiting
+	Hysteresis uint8 // 0 default, 1 disable, 2 enable
+	BackPower  uint8 // 0 none, 1 the board backpowers the Pi with 1.3 A, 2 with 2 A
+	Pins       [GPIO_COUNT]GPIO
+}
+
+// Atom is an atom of a type this package does not interpret.
+type Atom struct {
+	Type uint16
+	Data []byte
+}
+
+// EEPROM is the content of an ID EEPROM. Atoms are serialized in the order
+// vendor info, GPIO map, DT blob, custom data, others.
+type EEPROM struct {
+	Vendor  *VendorInfo
+	GPIO    *GPIOMap
+	DTBlob  []byte   // a device tree overlay, or the name of one
+	Custom  [][]byte // manufacturer data
+	Unknown []Atom
+}
+
+// CRC16 computes the CRC of an atom: CRC-16/ARC, as in eepmake.
+func CRC16(p []byte) uint16 {
+	var crc uint16
+	for _, b := range p {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// Decode parses an EEPROM image, validating every atom.
+func Decode(b []byte) (e *EEPROM, err error) {
+	le := binary.LittleEndian
+	if len(b) < HEADER_SIZE {
+		return nil, ErrTruncated
+	}
+	if string(b[:4]) != SIGNATURE {
+		return nil, ErrSignature
+	}
+	if b[4] != VERSION {
+		return nil, ErrVersion
+	}
+	count := int(le.Uint16(b[6:]))
+	size := uint64(le.Uint32(b[8:]))
+	if size < HEADER_SIZE || size > uint64(len(b)) {
+		return nil, ErrTruncated
+	}
+	b = b[:size]
+	e = &EEPROM{}
+	at := HEADER_SIZE
+	for i := 0; i < count; i++ {
+		if at+ATOM_HEADER > len(b) {
+			return nil, ErrTruncated
+		}
+		typ := le.Uint16(b[at:])
+		dlen := uint64(le.Uint32(b[at+4:]))
+		if dlen < CRC_SIZE || dlen > uint64(len(b)-at-ATOM_HEADER) {
+			return nil, ErrTruncated
+		}
+		end := at + ATOM_HEADER + int(dlen) - CRC_SIZE
+		if CRC16(b[at:end]) != le.Uint16(b[end:]) {
+			return nil, &CRCError{i, typ}
+		}
+		data := b[at+ATOM_HEADER : end]
+		switch typ {
+		case ATOM_VENDOR:
+			if e.Vendor, err = decodeVendor(data); err != nil {
+				return nil, err
+			}
+		case ATOM_GPIO_MAP:
+			if e.GPIO, err = decodeGPIOMap(data); err != nil {
+				return nil, err
+			}
+		case ATOM_DT_BLOB:
+			e.DTBlob = clone(data)
+		case ATOM_CUSTOM:
+			e.Custom = append(e.Custom, clone(data))
+		case ATOM_INVALID, ATOM_INVALID_2:
+			return nil, ErrAtomType
+		default:
+			e.Unknown = append(e.Unknown, Atom{typ, clone(data)})
+		}
+		at = end + CRC_SIZE
+	}
+	return
+}
+
+func decodeVendor(b []byte) (v *VendorInfo, err error) {
+	if len(b) < 22 {
+		return nil, ErrTruncated
+	}
+	v = &VendorInfo{}
+	for i := range v.UUID {
+		v.UUID[i] = b[15-i]
+	}
+	v.ProductID = binary.LittleEndian.Uint16(b[16:])
+	v.ProductVer = binary.LittleEndian.Uint16(b[18:])
+	vslen, pslen := int(b[20]), int(b[21])
+	if len(b) < 22+vslen+pslen {
+		return nil, ErrTruncated
+	}
+	v.Vendor = string(b[22 : 22+vslen])
+	v.Product = string(b[22+vslen : 22+vslen+pslen])
+	return
+}
+
+func decodeGPIOMap(b []byte) (m *GPIOMap, err error) {
+	if len(b) < 2+GPIO_COUNT {
+		return nil, ErrTruncated
+	}
+	m = &GPIOMap{
+		Drive:      b[0] & 0x0F,
+		Slew:       b[0] >> 4 & 0x03,
+		Hysteresis: b[0] >> 6,
+		BackPower:  b[1] & 0x03,
+	}
+	for i := range m.Pins {
+		g := b[2+i]
+		m.Pins[i] = GPIO{Used: g&0x80 != 0, Function: g & 0x07, Pull: g >> 5 & 0x03}
+	}
+	return
+}
+
+// Encode serializes e into an EEPROM image.
+func (this *EEPROM) Encode() (b []byte, err error) {
+	var atoms []Atom
+	if this.Vendor != nil {
+		var data []byte
+		if data, err = this.Vendor.encode(); err != nil {
+			return
+		}
+		atoms = append(atoms, Atom{ATOM_VENDOR, data})
+	}
+	if this.GPIO != nil {
+		atoms = append(atoms, Atom{ATOM_GPIO_MAP, this.GPIO.encode()})
+	}
+	if this.DTBlob != nil {
+		atoms = append(atoms, Atom{ATOM_DT_BLOB, this.DTBlob})
+	}
+	for _, data := range this.Custom {
+		atoms = append(atoms, Atom{ATOM_CUSTOM, data})
+	}
+	for _, atom := range this.Unknown {
+		if atom.Type == ATOM_INVALID || atom.Type == ATOM_INVALID_2 {
+			return nil, ErrAtomType
+		}
+		atoms = append(atoms, atom)
+	}
+	le := binary.LittleEndian
+	b = make([]byte, HEADER_SIZE)
+	copy(b, SIGNATURE)
+	b[4] = VERSION
+	le.PutUint16(b[6:], uint16(len(atoms)))
+	for i, atom := range atoms {
+		at := len(b)
+		b = append(b, make([]byte, ATOM_HEADER)...)
+		le.PutUint16(b[at:], atom.Type)
+		le.PutUint16(b[at+2:], uint16(i))
+		le.PutUint32(b[at+4:], uint32(len(atom.Data)+CRC_SIZE))
+		b = append(b, atom.Data...)
+		crc := CRC16(b[at:])
+		b = append(b, byte(crc), byte(crc>>8))
+	}
+	le.PutUint32(b[8:], uint32(len(b)))
+	return
+}
+
+func (this *VendorInfo) encode() ([]byte, error) {
+	if len(this.Vendor) > 255 || len(this.Product) > 255 {
+		return nil, ErrTooLong
+	}
+	b := make([]byte, 22, 22+len(this.Vendor)+len(this.Product))
+	for i := range this.UUID {
+		b[15-i] = this.UUID[i]
+	}
+	binary.LittleEndian.PutUint16(b[16:], this.ProductID)
+	binary.LittleEndian.PutUint16(b[18:], this.ProductVer)
+	b[20], b[21] = byte(len(this.Vendor)), byte(len(this.Product))
+	b = append(b, this.Vendor...)
+	return append(b, this.Product...), nil
+}
+
+func (this *GPIOMap) encode() []byte {
+	b := make([]byte, 2+GPIO_COUNT)
+	b[0] = this.Drive&0x0F | (this.Slew&0x03)<<4 | this.Hysteresis<<6
+	b[1] = this.BackPower & 0x03
+	for i, g := range this.Pins {
+		v := g.Function&0x07 | (g.Pull&0x03)<<5
+		if g.Used {
+			v |= 0x80
+		}
+		b[2+i] = v
+	}
+	return b
+}
+
+func clone(b []byte) []byte {
+	p := make([]byte, len(b))
+	copy(p, b)
+	return p
+}
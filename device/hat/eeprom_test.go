@@ -0,0 +1,180 @@
+package hat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/zyxar/berry/bus/bustest"
+)
+
+const vendorImage = "522d50690100010033000000010000001f0000006b5a4f3e2d1c0b9a884796a5b4c3d2f134120200040341434d45666f6fa4fa"
+
+func TestCRC16(t *testing.T) {
+	if crc := CRC16([]byte("123456789")); crc != 0xBB3D {
+		t.Errorf("crc mismatch: %04x", crc)
+	}
+}
+
+func TestUUID(t *testing.T) {
+	const s = "f1d2c3b4-a596-4788-9a0b-1c2d3e4f5a6b"
+	u, err := ParseUUID(s)
+	if err != nil || u.String() != s {
+		t.Errorf("uuid mismatch: %v %v", u, err)
+	}
+	for _, s := range []string{
+		"",
+		"f1d2c3b4a5964788-9a0b-1c2d3e4f5a6b0000",
+		"f1d2c3b4-a596-4788-9a0b-1c2d3e4f5a6x",
+		"f1d2c3b4a5964788f1d2c3b4a5964788abcd",
+		"f1d2c3b4-a5964-788-9a0b-1c2d3e4f5a6b",
+	} {
+		if _, err = ParseUUID(s); err == nil {
+			t.Errorf("%q: error expected", s)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	u, _ := ParseUUID("f1d2c3b4-a596-4788-9a0b-1c2d3e4f5a6b")
+	e := &EEPROM{Vendor: &VendorInfo{UUID: u, ProductID: 0x1234, ProductVer: 2, Vendor: "ACME", Product: "foo"}}
+	b, err := e.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(b) != vendorImage {
+		t.Errorf("image mismatch: %x", b)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	e := &EEPROM{
+		Vendor: &VendorInfo{ProductID: 1, ProductVer: 1, Vendor: "ACME", Product: "Relay HAT"},
+		GPIO:   &GPIOMap{Drive: 4, Slew: 2, Hysteresis: 1, BackPower: 1},
+		DTBlob: []byte("relay-hat"),
+		Custom: [][]byte{[]byte("serial=0042"), {}},
+		Unknown: []Atom{
+			{0x0100, []byte{1, 2, 3}},
+		},
+	}
+	e.GPIO.Pins[4] = GPIO{Used: true, Function: GPIO_OUTPUT, Pull: PULL_NONE}
+	e.GPIO.Pins[17] = GPIO{Used: true, Function: GPIO_ALT5, Pull: PULL_UP}
+	b, err := e.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d, e) {
+		t.Errorf("round trip mismatch:\n%+v\n%+v", d, e)
+	}
+
+	b[len(b)-5] ^= 0xFF
+	if _, err = Decode(b); err == nil {
+		t.Errorf("crc error expected")
+	} else if e, ok := err.(*CRCError); !ok || e.Index != 5 || e.Type != 0x0100 {
+		t.Errorf("crc error mismatch: %v", err)
+	}
+	if _, err = Decode(b[:len(b)-1]); err != ErrTruncated {
+		t.Errorf("%v expected, got %v", ErrTruncated, err)
+	}
+	b[0] = 'r'
+	if _, err = Decode(b); err != ErrSignature {
+		t.Errorf("%v expected, got %v", ErrSignature, err)
+	}
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	image, _ := hex.DecodeString(vendorImage)
+	for name, patch := range map[string]func(b []byte){
+		"blank size":  func(b []byte) { copy(b[8:], []byte{0xFF, 0xFF, 0xFF, 0xFF}) },
+		"short size":  func(b []byte) { copy(b[6:], []byte{0, 0, 4, 0, 0, 0}) },
+		"huge atom":   func(b []byte) { copy(b[16:], []byte{0xFC, 0xFF, 0xFF, 0x7F}) },
+		"blank atom":  func(b []byte) { copy(b[16:], []byte{0xFF, 0xFF, 0xFF, 0xFF}) },
+		"empty atom":  func(b []byte) { copy(b[16:], []byte{1, 0, 0, 0}) },
+		"extra atoms": func(b []byte) { b[6] = 2 },
+	} {
+		b := append([]byte(nil), image...)
+		patch(b)
+		if _, err := Decode(b); err != ErrTruncated {
+			t.Errorf("%s: %v expected, got %v", name, ErrTruncated, err)
+		}
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	image, _ := hex.DecodeString(vendorImage)
+	m := bustest.NewI2C(
+		bustest.Op{Kind: bustest.TX, W: []byte{0, 0}, R: image[:HEADER_SIZE]},
+		bustest.Op{Kind: bustest.TX, W: []byte{0, HEADER_SIZE}, R: image[HEADER_SIZE:]},
+	)
+	e, err := Read(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Verify(); err != nil {
+		t.Error(err)
+	}
+	if e.Vendor == nil || e.Vendor.Vendor != "ACME" || e.Vendor.Product != "foo" || e.Vendor.ProductID != 0x1234 {
+		t.Errorf("vendor mismatch: %+v", e.Vendor)
+	}
+
+	m = bustest.NewI2C(
+		bustest.Op{Kind: bustest.WRITE, W: append([]byte{0, 0}, image[:32]...)},
+		bustest.Op{Kind: bustest.WRITE, W: append([]byte{0, 32}, image[32:]...)},
+		bustest.Op{Kind: bustest.TX, W: []byte{0, 0}, R: image},
+	)
+	if err = Write(m, e); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	bad := append([]byte(nil), image...)
+	bad[20] = 0
+	m = bustest.NewI2C(
+		bustest.Op{Kind: bustest.WRITE, W: append([]byte{0, 0}, image[:32]...)},
+		bustest.Op{Kind: bustest.WRITE, W: append([]byte{0, 32}, image[32:]...)},
+		bustest.Op{Kind: bustest.TX, W: []byte{0, 0}, R: bad},
+	)
+	if err = WriteImage(m, image); err != ErrVerify {
+		t.Errorf("%v expected, got %v", ErrVerify, err)
+	}
+}
+
+func TestReadLarge(t *testing.T) {
+	image := make([]byte, 10000)
+	copy(image, SIGNATURE)
+	binary.LittleEndian.PutUint32(image[8:], uint32(len(image)))
+	m := bustest.NewI2C(
+		bustest.Op{Kind: bustest.TX, W: []byte{0x00, 0x00}, R: image[:HEADER_SIZE]},
+		bustest.Op{Kind: bustest.TX, W: []byte{0x00, 0x0C}, R: image[HEADER_SIZE : HEADER_SIZE+READ_CHUNK]},
+		bustest.Op{Kind: bustest.TX, W: []byte{0x10, 0x0C}, R: image[HEADER_SIZE+READ_CHUNK : HEADER_SIZE+2*READ_CHUNK]},
+		bustest.Op{Kind: bustest.TX, W: []byte{0x20, 0x0C}, R: image[HEADER_SIZE+2*READ_CHUNK:]},
+	)
+	b, err := ReadImage(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Verify(); err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(b, image) {
+		t.Error("image mismatch")
+	}
+}
+
+func TestEncodeInvalid(t *testing.T) {
+	long := string(bytes.Repeat([]byte{'x'}, 256))
+	if _, err := (&EEPROM{Vendor: &VendorInfo{Vendor: long}}).Encode(); err != ErrTooLong {
+		t.Errorf("%v expected, got %v", ErrTooLong, err)
+	}
+	if _, err := (&EEPROM{Unknown: []Atom{{ATOM_INVALID_2, nil}}}).Encode(); err != ErrAtomType {
+		t.Errorf("%v expected, got %v", ErrAtomType, err)
+	}
+}
@@ -0,0 +1,105 @@
+package hat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/zyxar/berry/bus"
+)
+
+const (
+	EEPROM_BUS  = 0    // i2c-0, the ID_SD/ID_SC pins
+	EEPROM_ADDR = 0x50 // 24C32 or compatible, 16-bit word addresses
+
+	PAGE_SIZE   = 32
+	WRITE_CYCLE = 5 * time.Millisecond
+	READ_CHUNK  = 4096 // bytes per read, i2c-dev rejects messages over 8192 bytes
+)
+
+var (
+	ErrVerify    = errors.New("hat: eeprom verification failed")
+	ErrImageSize = errors.New("hat: image exceeds 16-bit address space")
+)
+
+// ReadImage reads the image from the EEPROM at conn, sized by its header.
+func ReadImage(conn bus.I2CConn) (b []byte, err error) {
+	header := make([]byte, HEADER_SIZE)
+	if err = readAt(conn, 0, header); err != nil {
+		return
+	}
+	if string(header[:4]) != SIGNATURE {
+		return nil, ErrSignature
+	}
+	size := binary.LittleEndian.Uint32(header[8:])
+	if size < HEADER_SIZE || size > 0x10000 {
+		return nil, ErrTruncated
+	}
+	b = make([]byte, size)
+	copy(b, header)
+	if err = readAt(conn, HEADER_SIZE, b[HEADER_SIZE:]); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Read reads and decodes the EEPROM at conn.
+func Read(conn bus.I2CConn) (*EEPROM, error) {
+	b, err := ReadImage(conn)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(b)
+}
+
+// WriteImage programs b into the EEPROM at conn a page at a time, then reads
+// it back. The write protect pin of the HAT must be pulled low.
+func WriteImage(conn bus.I2CConn, b []byte) (err error) {
+	if len(b) > 0x10000 {
+		return ErrImageSize
+	}
+	for at := 0; at < len(b); {
+		n := PAGE_SIZE - at%PAGE_SIZE
+		if n > len(b)-at {
+			n = len(b) - at
+		}
+		if err = conn.Write(append([]byte{byte(at >> 8), byte(at)}, b[at:at+n]...)...); err != nil {
+			return
+		}
+		time.Sleep(WRITE_CYCLE)
+		at += n
+	}
+	p := make([]byte, len(b))
+	if err = readAt(conn, 0, p); err != nil {
+		return
+	}
+	if !bytes.Equal(p, b) {
+		err = ErrVerify
+	}
+	return
+}
+
+// Write encodes e and programs it into the EEPROM at conn.
+func Write(conn bus.I2CConn, e *EEPROM) error {
+	b, err := e.Encode()
+	if err != nil {
+		return err
+	}
+	return WriteImage(conn, b)
+}
+
+// readAt reads p from at, in chunks of at most READ_CHUNK bytes.
+func readAt(conn bus.I2CConn, at int, p []byte) error {
+	for len(p) > 0 {
+		n := len(p)
+		if n > READ_CHUNK {
+			n = READ_CHUNK
+		}
+		if err := conn.Tx([]byte{byte(at >> 8), byte(at)}, p[:n]); err != nil {
+			return err
+		}
+		at, p = at+n, p[n:]
+	}
+	return nil
+}
@@ -23,11 +23,16 @@ func Open() (*Device, error) {
 	if err != nil {
 		return nil, err
 	}
+	return New(dev)
+}
+
+// New resets the reader attached to dev and turns on its antenna.
+func New(dev bus.SPIBus) (*Device, error) {
 	d := &Device{dev}
-	if err = d.Reset(); err != nil {
+	if err := d.Reset(); err != nil {
 		return nil, err
 	}
-	if err = d.EnableAntenna(); err != nil {
+	if err := d.EnableAntenna(); err != nil {
 		return nil, err
 	}
 	return d, nil
@@ -2,13 +2,18 @@ package sys
 
 import (
 	"bufio"
+	"encoding/hex"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 )
 
-type coreinfo struct {
+// CoreInfo describes a processor in /proc/cpuinfo.
+type CoreInfo struct {
 	Processor uint
 	ModelName string
 	BogoMIPS  float64
@@ -22,167 +27,166 @@ type coreinfo struct {
 	}
 }
 
-type cpuinfo struct {
-	Cores    []coreinfo
+// CPU is the content of /proc/cpuinfo. Model and Serial come from the device
+// tree when the kernel does not report them.
+type CPU struct {
+	Cores    []CoreInfo
 	Hardware string
 	Revision uint64
 	Serial   []byte
+	Model    string
 }
 
 var (
-	cpuInfo *cpuinfo
+	cpuInfo     *CPU // nil until read successfully
+	cpuInfoLock sync.RWMutex
 )
 
-func CPUInfo() (*cpuinfo, error) {
-	if cpuInfo != nil {
-		return cpuInfo, nil
+// CPUInfo returns /proc/cpuinfo, read on the first successful call.
+func CPUInfo() (CPU, error) {
+	cpuInfoLock.RLock()
+	info := cpuInfo
+	cpuInfoLock.RUnlock()
+	if info != nil {
+		return info.clone(), nil
 	}
-	var (
-		file *os.File
-		err  error
-	)
-	if file, err = os.Open("/proc/cpuinfo"); err != nil {
-		return nil, err
+	return RefreshCPUInfo()
+}
+
+// RefreshCPUInfo reads /proc/cpuinfo again, e.g. after CPU hotplug. A failed
+// read leaves the cached info alone.
+func RefreshCPUInfo() (info CPU, err error) {
+	if info, err = readCPUInfo("/"); err != nil {
+		return CPU{}, err
 	}
-	cpuInfo = &cpuinfo{}
-	if err = decodeCpuInfo(file, cpuInfo); err != nil {
-		cpuInfo = nil
+	cpuInfoLock.Lock()
+	cpuInfo = &info
+	cpuInfoLock.Unlock()
+	return info.clone(), nil
+}
+
+// clone copies the slices of this, so callers cannot change the cached info.
+func (this CPU) clone() CPU {
+	cores := make([]CoreInfo, len(this.Cores))
+	for i, core := range this.Cores {
+		core.Features = append([]string(nil), core.Features...)
+		cores[i] = core
 	}
+	this.Cores = cores
+	this.Serial = append([]byte(nil), this.Serial...)
+	return this
+}
+
+func readCPUInfo(root string) (info CPU, err error) {
+	file, err := os.Open(filepath.Join(root, "proc/cpuinfo"))
+	if err != nil {
+		return
+	}
+	err = decodeCpuInfo(file, &info)
 	file.Close()
-	return cpuInfo, err
+	if err != nil {
+		return
+	}
+	dt := filepath.Join(root, "proc/device-tree")
+	if info.Model == "" {
+		if b, err := ioutil.ReadFile(filepath.Join(dt, "model")); err == nil {
+			info.Model = strings.TrimRight(string(b), "\x00\n")
+		}
+	}
+	if info.Serial == nil {
+		if b, err := ioutil.ReadFile(filepath.Join(dt, "serial-number")); err == nil {
+			info.Serial = parseSerial(strings.TrimRight(string(b), "\x00\n"))
+		}
+	}
+	return
 }
 
-func decodeCpuInfo(r io.Reader, info *cpuinfo) error {
-	rd := bufio.NewReader(r)
-	var (
-		processors       []uint
-		modelNames       []string
-		bogomips         []float64
-		features         [][]string
-		cpuImplementers  []byte
-		cpuArchitectures []byte
-		cpuVariants      []byte
-		cpuParts         []uint16
-		cpuRevisions     []byte
-	)
-	for {
-		line, err := rd.ReadString('\n')
-		if err != nil {
-			break
+// decodeCpuInfo parses blocks separated by blank lines. A block starting a
+// processor describes a core; other fields, such as Hardware on 32-bit ARM
+// kernels, may follow in a block of their own. Missing or malformed fields
+// are left zero.
+func decodeCpuInfo(r io.Reader, info *CPU) error {
+	scanner := bufio.NewScanner(r)
+	var core *CoreInfo
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			core = nil
+			continue
 		}
-		line = strings.Trim(line, "\n")
-		fields := strings.Split(line, ":")
+		fields := strings.SplitN(line, ":", 2)
 		if len(fields) < 2 {
 			continue
 		}
 		key := strings.TrimSpace(fields[0])
 		val := strings.TrimSpace(fields[1])
-		switch strings.ToLower(key) {
-		default:
-
-		case "processor":
-			u, err := strconv.ParseUint(val, 10, 64)
-			if err != nil {
-				return err
-			}
-			processors = append(processors, uint(u))
-		case "model name":
-			modelNames = append(modelNames, val)
-		case "bogomips":
-			f, err := strconv.ParseFloat(val, 64)
-			if err != nil {
-				return err
-			}
-			bogomips = append(bogomips, f)
-		case "features":
-			features = append(features, strings.Split(val, " "))
-		case "cpu implementer":
-			var v uint64
-			if strings.HasPrefix(val, "0x") {
-				v, err = strconv.ParseUint(val[2:], 16, 8)
-			} else {
-				v, err = strconv.ParseUint(val, 10, 8)
-			}
-			if err != nil {
-				return err
-			}
-			cpuImplementers = append(cpuImplementers, byte(v))
-		case "cpu architecture":
-			var v uint64
-			if strings.HasPrefix(val, "0x") {
-				v, err = strconv.ParseUint(val[2:], 16, 8)
-			} else {
-				v, err = strconv.ParseUint(val, 10, 8)
-			}
-			if err != nil {
-				return err
-			}
-			cpuArchitectures = append(cpuArchitectures, byte(v))
-		case "cpu variant":
-			var v uint64
-			if strings.HasPrefix(val, "0x") {
-				v, err = strconv.ParseUint(val[2:], 16, 8)
-			} else {
-				v, err = strconv.ParseUint(val, 10, 8)
+		if key == "processor" {
+			info.Cores = append(info.Cores, CoreInfo{})
+			core = &info.Cores[len(info.Cores)-1]
+			if v, err := strconv.ParseUint(val, 10, 32); err == nil {
+				core.Processor = uint(v)
 			}
-			if err != nil {
-				return err
-			}
-			cpuVariants = append(cpuVariants, byte(v))
-		case "cpu part":
-			var v uint64
-			if strings.HasPrefix(val, "0x") {
-				v, err = strconv.ParseUint(val[2:], 16, 16)
-			} else {
-				v, err = strconv.ParseUint(val, 10, 8)
-			}
-			if err != nil {
-				return err
-			}
-			cpuParts = append(cpuParts, uint16(v))
-		case "cpu revision":
-			var v uint64
-			if strings.HasPrefix(val, "0x") {
-				v, err = strconv.ParseUint(val[2:], 16, 8)
-			} else {
-				v, err = strconv.ParseUint(val, 10, 8)
-			}
-			if err != nil {
-				return err
-			}
-			cpuRevisions = append(cpuRevisions, byte(v))
-		case "hardware":
+			continue
+		}
+		switch key {
+		case "Hardware":
 			info.Hardware = val
-		case "revision":
-			var v uint64
-			if v, err = strconv.ParseUint(val, 16, 64); err != nil {
-				return err
+			continue
+		case "Revision":
+			if v, err := strconv.ParseUint(val, 16, 64); err == nil {
+				info.Revision = v
 			}
-			info.Revision = v
-		case "serial":
-			l := len(val) / 2
-			info.Serial = make([]byte, l)
-			var v uint64
-			for i := 0; i < l; i++ {
-				if v, err = strconv.ParseUint(val[i*2:i*2+2], 16, 8); err != nil {
-					return err
-				}
-				info.Serial[i] = byte(v)
+			continue
+		case "Serial":
+			info.Serial = parseSerial(val)
+			continue
+		case "Model":
+			info.Model = val
+			continue
+		}
+		if core == nil {
+			continue
+		}
+		switch key {
+		case "model name":
+			core.ModelName = val
+		case "BogoMIPS", "bogomips":
+			if v, err := strconv.ParseFloat(val, 64); err == nil {
+				core.BogoMIPS = v
 			}
+		case "Features", "flags":
+			core.Features = strings.Fields(val)
+		case "CPU implementer":
+			core.CPU.Implementer = byte(parseHex(val, 8))
+		case "CPU architecture":
+			v, _ := strconv.ParseUint(val, 10, 8)
+			core.CPU.Architecture = byte(v)
+		case "CPU variant":
+			core.CPU.Variant = byte(parseHex(val, 8))
+		case "CPU part":
+			core.CPU.Part = uint16(parseHex(val, 16))
+		case "CPU revision":
+			v, _ := strconv.ParseUint(val, 10, 8)
+			core.CPU.Revision = byte(v)
 		}
 	}
-	count := len(processors)
-	info.Cores = make([]coreinfo, count)
-	for i := 0; i < count; i++ {
-		info.Cores[i].Processor = processors[i]
-		info.Cores[i].ModelName = modelNames[i]
-		info.Cores[i].BogoMIPS = bogomips[i]
-		info.Cores[i].Features = features[i]
-		info.Cores[i].CPU.Implementer = cpuImplementers[i]
-		info.Cores[i].CPU.Architecture = cpuArchitectures[i]
-		info.Cores[i].CPU.Variant = cpuVariants[i]
-		info.Cores[i].CPU.Part = cpuParts[i]
-		info.Cores[i].CPU.Revision = cpuRevisions[i]
+	return scanner.Err()
+}
+
+// parseHex parses s as hex, with or without 0x, and 0 if malformed.
+func parseHex(s string, bits int) uint64 {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	v, _ := strconv.ParseUint(s, 16, bits)
+	return v
+}
+
+func parseSerial(s string) []byte {
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil
 	}
-	return nil
+	return b
 }
@@ -2,6 +2,8 @@ package sys
 
 import (
 	"bytes"
+	"encoding/hex"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -10,7 +12,7 @@ func TestDecode(t *testing.T) {
 	r := strings.NewReader(cpuInfoText)
 	var (
 		err  error
-		info = &cpuinfo{}
+		info = &CPU{}
 	)
 	if err = decodeCpuInfo(r, info); err != nil {
 		t.Error(err)
@@ -20,13 +22,13 @@ func TestDecode(t *testing.T) {
 		} else {
 			for n := range info.Cores {
 				if info.Cores[n].Processor != uint(n) {
-					t.Errorf("processor number mismatch: %s", info.Cores[n].Processor)
+					t.Errorf("processor number mismatch: %d", info.Cores[n].Processor)
 				}
 				if info.Cores[n].ModelName != "ARMv7 Processor rev 5 (v7l)" {
 					t.Errorf("Model name mismatch: %s", info.Cores[n].ModelName)
 				}
 				if info.Cores[n].BogoMIPS != 38.40 {
-					t.Errorf("BogoMIPS mismatch: %d", info.Cores[n].BogoMIPS)
+					t.Errorf("BogoMIPS mismatch: %v", info.Cores[n].BogoMIPS)
 				}
 				if info.Cores[n].CPU.Implementer != 0x41 {
 					t.Errorf("CPU implementer mismatch: %d", info.Cores[n].CPU.Implementer)
@@ -57,6 +59,61 @@ func TestDecode(t *testing.T) {
 	}
 }
 
+func TestReadCPUInfo(t *testing.T) {
+	for _, c := range []struct {
+		root     string
+		cores    int
+		model    string
+		hardware string
+		revision uint64
+		serial   string
+		core     CoreInfo
+	}{
+		{"pi-zero", 1, "Raspberry Pi Zero Rev 1.3", "BCM2835", 0x900093, "00000000a1b2c3d4",
+			CoreInfo{ModelName: "ARMv6-compatible processor rev 7 (v6l)", BogoMIPS: 697.95}},
+		{"pi4-arm64", 4, "Raspberry Pi 4 Model B Rev 1.4", "", 0xc03114, "100000002a3b4c5d",
+			CoreInfo{BogoMIPS: 108}},
+		{"pi5", 4, "Raspberry Pi 5 Model B Rev 1.0", "", 0xc04170, "6e1a2b3c4d5e6f70",
+			CoreInfo{BogoMIPS: 108}},
+		{"x86", 2, "", "", 0, "",
+			CoreInfo{ModelName: "Intel(R) Core(TM) i7-8550U CPU @ 1.80GHz", BogoMIPS: 3984}},
+	} {
+		info, err := readCPUInfo(filepath.Join("testdata", c.root))
+		if err != nil {
+			t.Errorf("%s: %v", c.root, err)
+			continue
+		}
+		if len(info.Cores) != c.cores || info.Model != c.model || info.Hardware != c.hardware ||
+			info.Revision != c.revision || hex.EncodeToString(info.Serial) != c.serial {
+			t.Errorf("%s: info mismatch: %+v", c.root, info)
+			continue
+		}
+		for n, core := range info.Cores {
+			if core.Processor != uint(n) || core.ModelName != c.core.ModelName || core.BogoMIPS != c.core.BogoMIPS ||
+				len(core.Features) == 0 {
+				t.Errorf("%s: core %d mismatch: %+v", c.root, n, core)
+			}
+		}
+	}
+	info, _ := readCPUInfo("testdata/pi5")
+	if cpu := info.Cores[3].CPU; cpu.Implementer != 0x41 || cpu.Architecture != 8 || cpu.Variant != 4 ||
+		cpu.Part != 0xd0b || cpu.Revision != 1 {
+		t.Errorf("pi5 cpu mismatch: %+v", cpu)
+	}
+}
+
+func TestDecodePartial(t *testing.T) {
+	info := &CPU{}
+	text := "processor : 0\nCPU implementer : 41\nCPU part : d03\n\nprocessor : 1\nBogoMIPS : bogus\n"
+	if err := decodeCpuInfo(strings.NewReader(text), info); err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Cores) != 2 || info.Cores[0].CPU.Implementer != 0x41 || info.Cores[0].CPU.Part != 0xd03 ||
+		info.Cores[1].Processor != 1 || info.Cores[1].BogoMIPS != 0 {
+		t.Errorf("info mismatch: %+v", info)
+	}
+}
+
 const cpuInfoText = `processor : 0
 model name  : ARMv7 Processor rev 5 (v7l)
 BogoMIPS  : 38.40
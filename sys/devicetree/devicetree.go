@@ -0,0 +1,320 @@
+// Package devicetree reads the flattened device tree exposed by the kernel
+// under /proc/device-tree.
+package devicetree
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const ROOT = "/proc/device-tree"
+
+var (
+	ErrNotCells  = errors.New("devicetree: property is not a list of cells")
+	ErrNotString = errors.New("devicetree: property is not a string")
+)
+
+// Tree is a device tree rooted at a directory of the procfs layout.
+type Tree struct {
+	root string
+}
+
+// Open opens the device tree of the running system.
+func Open() (*Tree, error) {
+	return OpenRoot(ROOT)
+}
+
+// OpenRoot opens the device tree in dir, e.g. a copy of /proc/device-tree.
+func OpenRoot(dir string) (*Tree, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: dir, Err: errors.New("not a directory")}
+	}
+	return &Tree{root: dir}, nil
+}
+
+// Root returns the root node.
+func (this *Tree) Root() *Node {
+	return &Node{tree: this, Path: "/"}
+}
+
+// Node returns the node at p, an absolute path like /soc/i2c@7e804000 or an
+// alias like i2c1, optionally followed by a relative path.
+func (this *Tree) Node(p string) (*Node, error) {
+	if !strings.HasPrefix(p, "/") {
+		alias, rest := p, ""
+		if i := strings.IndexByte(p, '/'); i >= 0 {
+			alias, rest = p[:i], p[i:]
+		}
+		target, err := this.Root().Child("aliases").ReadString(alias)
+		if err != nil {
+			return nil, err
+		}
+		p = target + rest
+	}
+	n := &Node{tree: this, Path: path.Clean(p)}
+	fi, err := os.Stat(n.dir())
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: n.dir(), Err: errors.New("not a node")}
+	}
+	return n, nil
+}
+
+// Model returns the model of the board, e.g. "Raspberry Pi 4 Model B Rev 1.4".
+func (this *Tree) Model() (string, error) {
+	return this.Root().ReadString("model")
+}
+
+// Compatible returns the compatible list of the board, most specific first.
+func (this *Tree) Compatible() ([]string, error) {
+	return this.Root().ReadStrings("compatible")
+}
+
+// Aliases maps alias names, such as i2c1 or spi0, to node paths.
+func (this *Tree) Aliases() (map[string]string, error) {
+	n := this.Root().Child("aliases")
+	names, err := n.Properties()
+	if err != nil {
+		return nil, err
+	}
+	aliases := make(map[string]string, len(names))
+	for _, name := range names {
+		if s, err := n.ReadString(name); err == nil && strings.HasPrefix(s, "/") {
+			aliases[name] = s
+		}
+	}
+	return aliases, nil
+}
+
+// Enabled reports whether the node at p, a path or alias, exists with an
+// okay status; drivers may check it before opening a bus.
+func (this *Tree) Enabled(p string) bool {
+	n, err := this.Node(p)
+	return err == nil && n.Enabled()
+}
+
+// Node is a device tree node.
+type Node struct {
+	tree *Tree
+	Path string
+}
+
+func (this *Node) dir() string {
+	return filepath.Join(this.tree.root, filepath.FromSlash(this.Path))
+}
+
+// Name returns the node name with its unit address, e.g. i2c@7e804000.
+func (this *Node) Name() string {
+	if this.Path == "/" {
+		return ""
+	}
+	return path.Base(this.Path)
+}
+
+// Parent returns the parent node, nil for the root.
+func (this *Node) Parent() *Node {
+	if this.Path == "/" {
+		return nil
+	}
+	return &Node{tree: this.tree, Path: path.Dir(this.Path)}
+}
+
+// Child returns the child called name, without checking it exists.
+func (this *Node) Child(name string) *Node {
+	return &Node{tree: this.tree, Path: path.Join(this.Path, name)}
+}
+
+// Children lists the child nodes by name.
+func (this *Node) Children() (nodes []*Node, err error) {
+	entries, err := ioutil.ReadDir(this.dir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			nodes = append(nodes, this.Child(entry.Name()))
+		}
+	}
+	return
+}
+
+// Properties lists the property names, sorted.
+func (this *Node) Properties() (names []string, err error) {
+	entries, err := ioutil.ReadDir(this.dir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// Property returns the raw value of the property called name.
+func (this *Node) Property(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(this.dir(), name))
+}
+
+// Has reports whether the node has the property called name.
+func (this *Node) Has(name string) bool {
+	fi, err := os.Stat(filepath.Join(this.dir(), name))
+	return err == nil && !fi.IsDir()
+}
+
+func (this *Node) ReadString(name string) (string, error) {
+	b, err := this.Property(name)
+	if err != nil {
+		return "", err
+	}
+	return DecodeString(b)
+}
+
+func (this *Node) ReadStrings(name string) ([]string, error) {
+	b, err := this.Property(name)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeStrings(b)
+}
+
+func (this *Node) ReadCells(name string) ([]uint32, error) {
+	b, err := this.Property(name)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeCells(b)
+}
+
+// ReadUint32 returns a property of a single cell.
+func (this *Node) ReadUint32(name string) (uint32, error) {
+	cells, err := this.ReadCells(name)
+	if err != nil {
+		return 0, err
+	}
+	if len(cells) != 1 {
+		return 0, ErrNotCells
+	}
+	return cells[0], nil
+}
+
+// Compatible returns the compatible list of the node, most specific first.
+func (this *Node) Compatible() []string {
+	s, _ := this.ReadStrings("compatible")
+	return s
+}
+
+// Status returns the status property, "okay" if absent.
+func (this *Node) Status() string {
+	s, err := this.ReadString("status")
+	if err != nil {
+		return "okay"
+	}
+	return s
+}
+
+// Enabled reports whether the status is "okay" (or the legacy "ok").
+func (this *Node) Enabled() bool {
+	s := this.Status()
+	return s == "okay" || s == "ok"
+}
+
+// AddressCells returns the #address-cells of the node, which applies to the
+// reg of its children: 2 if absent, as the specification says.
+func (this *Node) AddressCells() uint32 {
+	if v, err := this.ReadUint32("#address-cells"); err == nil {
+		return v
+	}
+	return 2
+}
+
+// SizeCells returns the #size-cells of the node: 1 if absent.
+func (this *Node) SizeCells() uint32 {
+	if v, err := this.ReadUint32("#size-cells"); err == nil {
+		return v
+	}
+	return 1
+}
+
+// Region is an entry of a reg property.
+type Region struct {
+	Address uint64
+	Size    uint64
+}
+
+// Reg decodes the reg property with the cell sizes of the parent node.
+func (this *Node) Reg() ([]Region, error) {
+	parent := this.Parent()
+	if parent == nil {
+		parent = this
+	}
+	cells, err := this.ReadCells("reg")
+	if err != nil {
+		return nil, err
+	}
+	return DecodeRegions(cells, parent.AddressCells(), parent.SizeCells())
+}
+
+// DecodeCells splits b into big-endian 32-bit cells.
+func DecodeCells(b []byte) ([]uint32, error) {
+	if len(b)%4 != 0 {
+		return nil, ErrNotCells
+	}
+	cells := make([]uint32, len(b)/4)
+	for i := range cells {
+		cells[i] = binary.BigEndian.Uint32(b[4*i:])
+	}
+	return cells, nil
+}
+
+// DecodeString returns a NUL-terminated string.
+func DecodeString(b []byte) (string, error) {
+	if len(b) == 0 || b[len(b)-1] != 0 || strings.IndexByte(string(b[:len(b)-1]), 0) >= 0 {
+		return "", ErrNotString
+	}
+	return string(b[:len(b)-1]), nil
+}
+
+// DecodeStrings returns a list of NUL-terminated strings.
+func DecodeStrings(b []byte) ([]string, error) {
+	if len(b) == 0 || b[len(b)-1] != 0 {
+		return nil, ErrNotString
+	}
+	return strings.Split(string(b[:len(b)-1]), "\x00"), nil
+}
+
+// Number joins n cells, most significant first, as in addresses and sizes.
+func Number(cells []uint32) (v uint64) {
+	for _, c := range cells {
+		v = v<<32 | uint64(c)
+	}
+	return
+}
+
+// DecodeRegions splits cells into address and size pairs.
+func DecodeRegions(cells []uint32, addressCells, sizeCells uint32) ([]Region, error) {
+	n := int(addressCells + sizeCells)
+	if n == 0 || len(cells)%n != 0 || addressCells > 2 || sizeCells > 2 {
+		return nil, ErrNotCells
+	}
+	regions := make([]Region, len(cells)/n)
+	for i := range regions {
+		entry := cells[i*n : (i+1)*n]
+		regions[i] = Region{Number(entry[:addressCells]), Number(entry[addressCells:])}
+	}
+	return regions, nil
+}
@@ -0,0 +1,135 @@
+package devicetree
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func cells(v ...uint32) string {
+	b := make([]byte, 4*len(v))
+	for i, c := range v {
+		binary.BigEndian.PutUint32(b[4*i:], c)
+	}
+	return string(b)
+}
+
+// fakeTree lays out a Pi 4 like tree in a temporary directory.
+func fakeTree(t *testing.T) *Tree {
+	root := t.TempDir()
+	for p, v := range map[string]string{
+		"model":                       "Raspberry Pi 4 Model B Rev 1.4\x00",
+		"compatible":                  "raspberrypi,4-model-b\x00brcm,bcm2711\x00",
+		"#address-cells":              cells(2),
+		"#size-cells":                 cells(1),
+		"aliases/i2c1":                "/soc/i2c@7e804000\x00",
+		"aliases/spi0":                "/soc/spi@7e204000\x00",
+		"aliases/name":                "aliases\x00",
+		"soc/#address-cells":          cells(1),
+		"soc/#size-cells":             cells(1),
+		"soc/i2c@7e804000/compatible": "brcm,bcm2711-i2c\x00brcm,bcm2835-i2c\x00",
+		"soc/i2c@7e804000/reg":        cells(0x7e804000, 0x1000),
+		"soc/i2c@7e804000/status":     "okay\x00",
+		"soc/spi@7e204000/reg":        cells(0x7e204000, 0x200),
+		"soc/spi@7e204000/status":     "disabled\x00",
+		"soc/gpio@7e200000/reg":       cells(0x7e200000, 0xb4),
+		"memory@0/reg":                cells(0, 0, 0x3b400000, 0, 0x40000000, 0xbc000000),
+		"memory@0/device_type":        "memory\x00",
+		"hat/vendor":                  "Example Ltd\x00",
+		"hat/product":                 "Sense HAT\x00",
+		"hat/product_id":              "0x0001\x00",
+		"hat/product_ver":             "0x0002\x00",
+		"hat/uuid":                    "0b9c4c23-7f32-4b2e-8f3e-2a1d7b8f9e10\x00",
+	} {
+		p = filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree, err := OpenRoot(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestTree(t *testing.T) {
+	tree := fakeTree(t)
+	if model, err := tree.Model(); err != nil || model != "Raspberry Pi 4 Model B Rev 1.4" {
+		t.Errorf("model mismatch: %q, %v", model, err)
+	}
+	if c, err := tree.Compatible(); err != nil || !reflect.DeepEqual(c, []string{"raspberrypi,4-model-b", "brcm,bcm2711"}) {
+		t.Errorf("compatible mismatch: %q, %v", c, err)
+	}
+	aliases, err := tree.Aliases()
+	if err != nil || len(aliases) != 2 || aliases["i2c1"] != "/soc/i2c@7e804000" {
+		t.Errorf("aliases mismatch: %v, %v", aliases, err)
+	}
+	i2c, err := tree.Node("i2c1")
+	if err != nil || i2c.Path != "/soc/i2c@7e804000" || i2c.Name() != "i2c@7e804000" {
+		t.Fatalf("alias lookup failed: %v, %v", i2c, err)
+	}
+	if !i2c.Enabled() || !tree.Enabled("i2c1") || tree.Enabled("spi0") || tree.Enabled("i2c0") {
+		t.Error("status mismatch")
+	}
+	if gpio, _ := tree.Node("/soc/gpio@7e200000"); gpio == nil || !gpio.Enabled() {
+		t.Error("node without status not okay")
+	}
+	if reg, err := i2c.Reg(); err != nil || !reflect.DeepEqual(reg, []Region{{0x7e804000, 0x1000}}) {
+		t.Errorf("reg mismatch: %v, %v", reg, err)
+	}
+	mem, _ := tree.Node("/memory@0")
+	if reg, err := mem.Reg(); err != nil || !reflect.DeepEqual(reg, []Region{{0, 0x3b400000}, {0x40000000, 0xbc000000}}) {
+		t.Errorf("memory reg mismatch: %v, %v", reg, err)
+	}
+	soc, err := tree.Node("/soc")
+	if nodes, _ := soc.Children(); err != nil || len(nodes) != 3 {
+		t.Errorf("children mismatch: %v, %v", nodes, err)
+	}
+	if _, err = tree.Node("/soc/i2c@7e804000/reg"); err == nil {
+		t.Error("property opened as node")
+	}
+	if _, err = i2c.ReadUint32("reg"); err != ErrNotCells {
+		t.Errorf("multi-cell property read as one: %v", err)
+	}
+	if _, err = i2c.ReadString("compatible"); err != ErrNotString {
+		t.Errorf("string list read as string: %v", err)
+	}
+}
+
+func TestHAT(t *testing.T) {
+	tree := fakeTree(t)
+	hat, err := tree.HAT()
+	if err != nil || hat.Vendor != "Example Ltd" || hat.Product != "Sense HAT" || hat.ProductID != 1 ||
+		hat.ProductVer != 2 || hat.UUID != "0b9c4c23-7f32-4b2e-8f3e-2a1d7b8f9e10" {
+		t.Errorf("hat mismatch: %+v, %v", hat, err)
+	}
+	os.RemoveAll(filepath.Join(tree.root, "hat"))
+	if _, err = tree.HAT(); !os.IsNotExist(err) {
+		t.Errorf("missing hat reported: %v", err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	if _, err := DecodeCells([]byte{1, 2, 3}); err != ErrNotCells {
+		t.Errorf("odd cells accepted: %v", err)
+	}
+	if _, err := DecodeString([]byte("abc")); err != ErrNotString {
+		t.Errorf("unterminated string accepted: %v", err)
+	}
+	if s, err := DecodeStrings([]byte("a\x00\x00b\x00")); err != nil || !reflect.DeepEqual(s, []string{"a", "", "b"}) {
+		t.Errorf("string list mismatch: %q, %v", s, err)
+	}
+	if v := Number([]uint32{0x1, 0x2}); v != 0x100000002 {
+		t.Errorf("number mismatch: %x", v)
+	}
+	if _, err := DecodeRegions([]uint32{1, 2, 3}, 1, 1); err != ErrNotCells {
+		t.Errorf("partial region accepted: %v", err)
+	}
+}
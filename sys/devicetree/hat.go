@@ -0,0 +1,43 @@
+package devicetree
+
+import (
+	"strconv"
+	"strings"
+)
+
+// HAT is the product info the firmware copies from a HAT ID EEPROM.
+type HAT struct {
+	Vendor     string
+	Product    string
+	ProductID  uint16
+	ProductVer uint16
+	UUID       string
+}
+
+// HAT returns the info of the attached HAT, an error if there is none.
+func (this *Tree) HAT() (hat HAT, err error) {
+	n, err := this.Node("/hat")
+	if err != nil {
+		return
+	}
+	if hat.Vendor, err = n.ReadString("vendor"); err != nil {
+		return
+	}
+	if hat.Product, err = n.ReadString("product"); err != nil {
+		return
+	}
+	hat.UUID, _ = n.ReadString("uuid")
+	hat.ProductID = hatNumber(n, "product_id")
+	hat.ProductVer = hatNumber(n, "product_ver")
+	return
+}
+
+// hatNumber parses a string like 0x0001.
+func hatNumber(n *Node, name string) uint16 {
+	s, err := n.ReadString(name)
+	if err != nil {
+		return 0
+	}
+	v, _ := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 16)
+	return uint16(v)
+}
@@ -0,0 +1,297 @@
+//go:build linux
+// +build linux
+
+package sys
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const SECTOR_SIZE = 512 // unit of the sector counters in /proc/diskstats
+
+// Mount is an entry of /proc/self/mounts.
+type Mount struct {
+	Device  string
+	Path    string
+	Type    string
+	Options []string
+}
+
+// ReadOnly tells whether the filesystem is mounted read-only.
+func (this Mount) ReadOnly() bool {
+	for _, opt := range this.Options {
+		if opt == "ro" {
+			return true
+		}
+	}
+	return false
+}
+
+// Filesystem is the usage of a mounted filesystem, in bytes.
+type Filesystem struct {
+	Mount
+	Size      uint64
+	Free      uint64
+	Avail     uint64 // free to unprivileged users
+	Files     uint64 // inodes
+	FilesFree uint64
+}
+
+// Used returns the bytes in use.
+func (this Filesystem) Used() uint64 {
+	return this.Size - this.Free
+}
+
+// UsedPercent returns the share of the space usable by unprivileged users
+// that is in use, as df(1) reports it.
+func (this Filesystem) UsedPercent() float64 {
+	if total := this.Used() + this.Avail; total > 0 {
+		return float64(this.Used()) * 100 / float64(total)
+	}
+	return 0
+}
+
+// DiskStats are the I/O counters of a block device since boot. Times are in
+// milliseconds. Discards are zero before Linux 4.18.
+type DiskStats struct {
+	Major, Minor   uint32
+	Name           string
+	Reads          uint64
+	ReadsMerged    uint64
+	ReadSectors    uint64
+	ReadTime       uint64
+	Writes         uint64
+	WritesMerged   uint64
+	WriteSectors   uint64
+	WriteTime      uint64
+	InFlight       uint64
+	IOTime         uint64
+	WeightedIOTime uint64
+	Discards       uint64
+	DiscardSectors uint64
+}
+
+const (
+	PRE_EOL_NORMAL  = 0x01 // from the eMMC EXT_CSD PRE_EOL_INFO
+	PRE_EOL_WARNING = 0x02 // 80% of the reserved blocks consumed
+	PRE_EOL_URGENT  = 0x03 // 90% of the reserved blocks consumed
+
+	LIFE_TIME_EXCEEDED = 0x0B // DEVICE_LIFE_TIME_EST beyond the rated life
+)
+
+// MMCInfo describes an SD card or eMMC. Life time estimates are reported by
+// eMMC and some industrial cards only, and left zero otherwise.
+type MMCInfo struct {
+	Device    string // e.g. mmcblk0
+	Type      string // SD or MMC
+	Name      string // product name
+	ManfID    uint32
+	OEMID     uint32
+	Serial    uint32
+	Date      string // month/year of manufacture
+	LifeTimeA uint8  // DEVICE_LIFE_TIME_EST_TYP_A: 0x01 for 0-10% used, ..., 0x0A for 90-100%, LIFE_TIME_EXCEEDED
+	LifeTimeB uint8  // DEVICE_LIFE_TIME_EST_TYP_B
+	PreEOL    uint8  // PRE_EOL_*
+}
+
+// LifeUsed returns the upper bound of the worse life time estimate, in
+// percent, capped at 100 once the rated life is exceeded, and false if the
+// card reports none. Reserved values are ignored.
+func (this MMCInfo) LifeUsed() (percent int, ok bool) {
+	v := this.lifeTime()
+	if v == 0 {
+		return
+	}
+	if v == LIFE_TIME_EXCEEDED {
+		return 100, true
+	}
+	return int(v) * 10, true
+}
+
+// LifeExceeded tells whether the card is used beyond its rated life.
+func (this MMCInfo) LifeExceeded() bool {
+	return this.lifeTime() == LIFE_TIME_EXCEEDED
+}
+
+// lifeTime returns the worse valid life time estimate, 0 if none.
+func (this MMCInfo) lifeTime() (v uint8) {
+	for _, e := range []uint8{this.LifeTimeA, this.LifeTimeB} {
+		if e <= LIFE_TIME_EXCEEDED && e > v {
+			v = e
+		}
+	}
+	return
+}
+
+// GetMounts returns the mounted filesystems.
+func GetMounts() ([]Mount, error) {
+	return readMounts("/")
+}
+
+// GetFilesystems returns the usage of the mounted filesystems backed by a
+// device, such as the SD card or USB storage. Pseudo filesystems and those
+// failing statfs are skipped.
+func GetFilesystems() (fss []Filesystem, err error) {
+	mounts, err := GetMounts()
+	if err != nil {
+		return
+	}
+	for _, m := range mounts {
+		if !strings.HasPrefix(m.Device, "/") {
+			continue
+		}
+		fs, err := statFilesystem(m)
+		if err != nil {
+			continue
+		}
+		fss = append(fss, fs)
+	}
+	return
+}
+
+// StatFilesystem returns the usage of the filesystem holding path. Only
+// Path of the mount is set.
+func StatFilesystem(path string) (Filesystem, error) {
+	return statFilesystem(Mount{Path: path})
+}
+
+func statFilesystem(m Mount) (fs Filesystem, err error) {
+	var st syscall.Statfs_t
+	if err = syscall.Statfs(m.Path, &st); err != nil {
+		err = os.NewSyscallError("statfs", err)
+		return
+	}
+	bsize := uint64(st.Bsize)
+	if st.Frsize > 0 {
+		bsize = uint64(st.Frsize)
+	}
+	fs = Filesystem{
+		Mount:     m,
+		Size:      uint64(st.Blocks) * bsize,
+		Free:      uint64(st.Bfree) * bsize,
+		Avail:     uint64(st.Bavail) * bsize,
+		Files:     uint64(st.Files),
+		FilesFree: uint64(st.Ffree),
+	}
+	return
+}
+
+// GetDiskStats returns the I/O counters of every block device and partition.
+func GetDiskStats() ([]DiskStats, error) {
+	return readDiskStats("/")
+}
+
+// GetMMCInfo returns the SD cards and eMMCs of the system.
+func GetMMCInfo() ([]MMCInfo, error) {
+	return readMMCInfo("/")
+}
+
+func readMounts(root string) (mounts []Mount, err error) {
+	file, err := os.Open(filepath.Join(root, "proc/self/mounts"))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		mounts = append(mounts, Mount{
+			Device:  unescapeMount(fields[0]),
+			Path:    unescapeMount(fields[1]),
+			Type:    fields[2],
+			Options: strings.Split(fields[3], ","),
+		})
+	}
+	err = scanner.Err()
+	return
+}
+
+// unescapeMount decodes the octal escapes of spaces, tabs, newlines and
+// backslashes in /proc/self/mounts.
+func unescapeMount(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b = append(b, byte(v))
+				i += 3
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+func readDiskStats(root string) (stats []DiskStats, err error) {
+	file, err := os.Open(filepath.Join(root, "proc/diskstats"))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 14 {
+			continue
+		}
+		var s DiskStats
+		major, _ := strconv.ParseUint(fields[0], 10, 32)
+		minor, _ := strconv.ParseUint(fields[1], 10, 32)
+		s.Major, s.Minor, s.Name = uint32(major), uint32(minor), fields[2]
+		for i, p := range []*uint64{
+			&s.Reads, &s.ReadsMerged, &s.ReadSectors, &s.ReadTime,
+			&s.Writes, &s.WritesMerged, &s.WriteSectors, &s.WriteTime,
+			&s.InFlight, &s.IOTime, &s.WeightedIOTime,
+			&s.Discards, nil, &s.DiscardSectors,
+		} {
+			if 3+i < len(fields) && p != nil {
+				*p, _ = strconv.ParseUint(fields[3+i], 10, 64)
+			}
+		}
+		stats = append(stats, s)
+	}
+	err = scanner.Err()
+	return
+}
+
+func readMMCInfo(root string) (infos []MMCInfo, err error) {
+	dirs, err := filepath.Glob(filepath.Join(root, "sys/block/mmcblk[0-9]*"))
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		name := filepath.Base(dir)
+		if _, e := strconv.Atoi(strings.TrimPrefix(name, "mmcblk")); e != nil {
+			continue // mmcblk0boot0, mmcblk0rpmb
+		}
+		dev := filepath.Join(dir, "device")
+		info := MMCInfo{
+			Device: name,
+			Type:   readString(filepath.Join(dev, "type")),
+			Name:   readString(filepath.Join(dev, "name")),
+			ManfID: uint32(parseHex(readString(filepath.Join(dev, "manfid")), 32)),
+			OEMID:  uint32(parseHex(readString(filepath.Join(dev, "oemid")), 32)),
+			Serial: uint32(parseHex(readString(filepath.Join(dev, "serial")), 32)),
+			Date:   readString(filepath.Join(dev, "date")),
+			PreEOL: uint8(parseHex(readString(filepath.Join(dev, "pre_eol_info")), 8)),
+		}
+		if life := strings.Fields(readString(filepath.Join(dev, "life_time"))); len(life) == 2 {
+			info.LifeTimeA = uint8(parseHex(life[0], 8))
+			info.LifeTimeB = uint8(parseHex(life[1], 8))
+		}
+		infos = append(infos, info)
+	}
+	return
+}
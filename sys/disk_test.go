@@ -0,0 +1,131 @@
+//go:build linux
+// +build linux
+
+package sys
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadMounts(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"proc/self/mounts": `/dev/mmcblk0p2 / ext4 rw,noatime 0 0
+proc /proc proc rw,relatime 0 0
+/dev/mmcblk0p1 /boot/firmware vfat ro,relatime,fmask=0022 0 0
+/dev/sda1 /media/pi/USB\040DISK vfat rw,nosuid,nodev 0 0
+tmpfs /run tmpfs rw,nosuid,nodev,size=88788k,mode=755 0 0
+`,
+	})
+	mounts, err := readMounts(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 5 {
+		t.Fatalf("mount number mismatch: %d", len(mounts))
+	}
+	if m := mounts[0]; m.Device != "/dev/mmcblk0p2" || m.Path != "/" || m.Type != "ext4" || m.ReadOnly() {
+		t.Errorf("root mismatch: %+v", m)
+	}
+	if m := mounts[2]; !m.ReadOnly() || !reflect.DeepEqual(m.Options, []string{"ro", "relatime", "fmask=0022"}) {
+		t.Errorf("boot mismatch: %+v", m)
+	}
+	if m := mounts[3]; m.Path != "/media/pi/USB DISK" {
+		t.Errorf("usb mismatch: %+v", m)
+	}
+	if s := unescapeMount(`a\134b\011c\0`); s != "a\\b\tc\\0" {
+		t.Errorf("unescape mismatch: %q", s)
+	}
+}
+
+func TestStatFilesystem(t *testing.T) {
+	fs, err := StatFilesystem(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs.Size == 0 || fs.Free > fs.Size || fs.Avail > fs.Free || fs.UsedPercent() < 0 || fs.UsedPercent() > 100 {
+		t.Errorf("usage mismatch: %+v", fs)
+	}
+	if _, err = StatFilesystem("/nonexistent"); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestReadDiskStats(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"proc/diskstats": `   1       0 ram0 0 0 0 0 0 0 0 0 0 0 0
+ 179       0 mmcblk0 25402 9877 1598310 129568 13771 17433 658696 383672 2 121704 513240
+ 179       1 mmcblk0p1 211 1147 10434 1136 2 0 2 0 0 700 1136 0 0 0 0 0 0
+   8       0 sda 1500 20 90000 3000 700 10 56000 4000 0 2500 7000 12 0 2048 30 5 40
+`,
+	})
+	stats, err := readDiskStats(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 4 {
+		t.Fatalf("disk number mismatch: %d", len(stats))
+	}
+	want := DiskStats{179, 0, "mmcblk0", 25402, 9877, 1598310, 129568, 13771, 17433, 658696, 383672, 2, 121704, 513240, 0, 0}
+	if stats[1] != want {
+		t.Errorf("mmcblk0 mismatch: %+v", stats[1])
+	}
+	if s := stats[3]; s.Name != "sda" || s.Discards != 12 || s.DiscardSectors != 2048 || s.WriteSectors*SECTOR_SIZE != 56000*512 {
+		t.Errorf("sda mismatch: %+v", s)
+	}
+}
+
+func TestReadMMCInfo(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"sys/block/mmcblk0/device/type":          "SD\n",
+		"sys/block/mmcblk0/device/name":          "SC32G\n",
+		"sys/block/mmcblk0/device/manfid":        "0x000003\n",
+		"sys/block/mmcblk0/device/oemid":         "0x5344\n",
+		"sys/block/mmcblk0/device/serial":        "0x1a2b3c4d\n",
+		"sys/block/mmcblk0/device/date":          "08/2021\n",
+		"sys/block/mmcblk1/device/type":          "MMC\n",
+		"sys/block/mmcblk1/device/life_time":     "0x02 0x04\n",
+		"sys/block/mmcblk1/device/pre_eol_info":  "0x01\n",
+		"sys/block/mmcblk1boot0/device/type":     "MMC\n",
+		"sys/block/mmcblk1rpmb/device/life_time": "0x02 0x04\n",
+	})
+	infos, err := readMMCInfo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("card number mismatch: %+v", infos)
+	}
+	sd := MMCInfo{Device: "mmcblk0", Type: "SD", Name: "SC32G", ManfID: 3, OEMID: 0x5344, Serial: 0x1a2b3c4d, Date: "08/2021"}
+	if infos[0] != sd {
+		t.Errorf("sd mismatch: %+v", infos[0])
+	}
+	if _, ok := infos[0].LifeUsed(); ok {
+		t.Error("sd life time reported")
+	}
+	emmc := infos[1]
+	if emmc.LifeTimeA != 2 || emmc.LifeTimeB != 4 || emmc.PreEOL != PRE_EOL_NORMAL {
+		t.Errorf("emmc mismatch: %+v", emmc)
+	}
+	if used, ok := emmc.LifeUsed(); !ok || used != 40 || emmc.LifeExceeded() {
+		t.Errorf("emmc life mismatch: %d %v", used, ok)
+	}
+	for _, c := range []struct {
+		a, b     uint8
+		used     int
+		ok, over bool
+	}{
+		{0x0A, 0x01, 100, true, false},
+		{0x01, LIFE_TIME_EXCEEDED, 100, true, true},
+		{0x0C, 0x00, 0, false, false},
+		{0xFF, 0x03, 30, true, false},
+	} {
+		info := MMCInfo{LifeTimeA: c.a, LifeTimeB: c.b}
+		if used, ok := info.LifeUsed(); used != c.used || ok != c.ok || info.LifeExceeded() != c.over {
+			t.Errorf("life 0x%02X/0x%02X mismatch: %d %v %v", c.a, c.b, used, ok, info.LifeExceeded())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package property encodes the property tag messages of the VideoCore
+// firmware mailbox, shared by sys and sys/mailbox.
+package property
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	PROCESS_REQUEST  = 0x00000000
+	RESPONSE_SUCCESS = 0x80000000
+	RESPONSE_ERROR   = 0x80000001
+	TAG_RESPONSE     = 0x80000000 // set in the code of an answered tag
+	TAG_END          = 0x00000000
+)
+
+var (
+	ErrFirmware    = errors.New("mailbox: request rejected by firmware")
+	ErrMessageSize = errors.New("mailbox: message too large")
+	ErrMalformed   = errors.New("mailbox: malformed response")
+)
+
+// Tag is a property tag. Size is the value buffer size, which must hold both
+// the request and the response; the firmware writes at most Size bytes back.
+type Tag struct {
+	ID       uint32
+	Request  []byte
+	Size     int
+	Response []byte // set by Decode
+}
+
+// NotAnsweredError reports a tag the firmware did not process, often unknown
+// to its version.
+type NotAnsweredError struct {
+	ID uint32
+}
+
+func (this *NotAnsweredError) Error() string {
+	return fmt.Sprintf("mailbox: tag 0x%08x not answered", this.ID)
+}
+
+// align4 rounds n up to a multiple of 4.
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// Encode builds the message carrying tags, all values little endian.
+func Encode(tags ...*Tag) (buf []byte, err error) {
+	size := 8 + 4 // header and end tag
+	for _, tag := range tags {
+		if tag.Size < len(tag.Request) {
+			tag.Size = len(tag.Request)
+		}
+		size += 12 + align4(tag.Size)
+	}
+	if size > 1<<20 {
+		err = ErrMessageSize
+		return
+	}
+	buf = make([]byte, size)
+	le := binary.LittleEndian
+	le.PutUint32(buf[0:], uint32(size))
+	le.PutUint32(buf[4:], PROCESS_REQUEST)
+	at := 8
+	for _, tag := range tags {
+		le.PutUint32(buf[at:], tag.ID)
+		le.PutUint32(buf[at+4:], uint32(tag.Size))
+		le.PutUint32(buf[at+8:], uint32(len(tag.Request)))
+		copy(buf[at+12:], tag.Request)
+		at += 12 + align4(tag.Size)
+	}
+	le.PutUint32(buf[at:], TAG_END)
+	return
+}
+
+// Decode sets the responses of tags from the message buf they were encoded in.
+func Decode(buf []byte, tags ...*Tag) error {
+	le := binary.LittleEndian
+	if len(buf) < 12 || int(le.Uint32(buf)) != len(buf) {
+		return ErrMalformed
+	}
+	switch le.Uint32(buf[4:]) {
+	case RESPONSE_SUCCESS:
+	case RESPONSE_ERROR:
+		return ErrFirmware
+	default:
+		return ErrMalformed
+	}
+	at := 8
+	for _, tag := range tags {
+		if at+12 > len(buf) || le.Uint32(buf[at:]) != tag.ID {
+			return ErrMalformed
+		}
+		size := int(le.Uint32(buf[at+4:]))
+		code := le.Uint32(buf[at+8:])
+		if code&TAG_RESPONSE == 0 {
+			return &NotAnsweredError{tag.ID}
+		}
+		n := int(code &^ TAG_RESPONSE)
+		if n > size {
+			n = size // truncated, the firmware had more to say
+		}
+		if at+12+align4(size) > len(buf) {
+			return ErrMalformed
+		}
+		tag.Response = append([]byte(nil), buf[at+12:at+12+n]...)
+		at += 12 + align4(size)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !mips && !mipsle && !mips64 && !mips64le && !ppc64 && !ppc64le
+// +build !mips,!mipsle,!mips64,!mips64le,!ppc64,!ppc64le
+
+package sys
+
+// ioctl number layout of <asm-generic/ioctl.h>
+const (
+	IOC_SIZEBITS = 14
+	IOC_DIRBITS  = 2
+	// Direction bits
+	IOC_NONE  = 0
+	IOC_WRITE = 1
+	IOC_READ  = 2
+)
@@ -0,0 +1,14 @@
+//go:build mips || mipsle || mips64 || mips64le || ppc64 || ppc64le
+// +build mips mipsle mips64 mips64le ppc64 ppc64le
+
+package sys
+
+// ioctl number layout of mips and powerpc <asm/ioctl.h>
+const (
+	IOC_SIZEBITS = 13
+	IOC_DIRBITS  = 3
+	// Direction bits
+	IOC_NONE  = 1
+	IOC_WRITE = 4
+	IOC_READ  = 2
+)
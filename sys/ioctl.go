@@ -1,16 +1,134 @@
 package sys
 
 import (
+	"errors"
 	"os"
+	"runtime"
 	"syscall"
+	"unsafe"
 )
 
+var (
+	ErrIoctlDir  = errors.New("sys: ioctl direction mismatch")
+	ErrIoctlSize = errors.New("sys: ioctl size mismatch")
+)
+
+// Ioctl runs request with a raw argument, retrying on EINTR. Prefer the typed
+// IoctlPtr, IoctlRead, IoctlWrite and IoctlSlice for pointer arguments.
 func Ioctl(fd, request, argp uintptr) error {
-	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, argp)
-	if errno == 0 {
+	for {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, argp)
+		switch errno {
+		case 0:
+			return nil
+		case syscall.EINTR:
+			continue
+		}
+		return os.NewSyscallError("ioctl", errno)
+	}
+}
+
+// ioctlPtr keeps p a pointer until the syscall, so the object cannot move.
+func ioctlPtr(fd, request uintptr, p unsafe.Pointer) error {
+	for {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(p))
+		switch errno {
+		case 0:
+			return nil
+		case syscall.EINTR:
+			continue
+		}
+		return os.NewSyscallError("ioctl", errno)
+	}
+}
+
+// checkIoctl validates the direction and size encoded in request against an
+// argument of size bytes, which the kernel accesses as dir (IOC_READ,
+// IOC_WRITE, both, or 0 for either). Legacy numbers, encoding neither, are
+// not checked.
+func checkIoctl(request, dir, size uintptr) error {
+	d, n := IOC_DIR(request), IOC_SIZE(request)
+	if isLegacyIoctl(request) {
 		return nil
 	}
-	return os.NewSyscallError("ioctl", errno)
+	if d&(IOC_READ|IOC_WRITE) == 0 || d&dir != dir {
+		return ErrIoctlDir
+	}
+	if n != size {
+		return ErrIoctlSize
+	}
+	return nil
+}
+
+// IoctlPtr runs request with a pointer to arg, which the kernel may read and
+// write.
+func IoctlPtr[T any](fd, request uintptr, arg *T) error {
+	if err := checkIoctl(request, 0, unsafe.Sizeof(*arg)); err != nil {
+		return err
+	}
+	err := ioctlPtr(fd, request, unsafe.Pointer(arg))
+	runtime.KeepAlive(arg)
+	return err
+}
+
+// IoctlRead runs an _IOR or _IOWR request, returning what the kernel wrote.
+func IoctlRead[T any](fd, request uintptr) (v T, err error) {
+	if err = checkIoctl(request, IOC_READ, unsafe.Sizeof(v)); err != nil {
+		return
+	}
+	err = ioctlPtr(fd, request, unsafe.Pointer(&v))
+	return
+}
+
+// IoctlWrite runs an _IOW or _IOWR request with a copy of v.
+func IoctlWrite[T any](fd, request uintptr, v T) error {
+	if err := checkIoctl(request, IOC_WRITE, unsafe.Sizeof(v)); err != nil {
+		return err
+	}
+	return ioctlPtr(fd, request, unsafe.Pointer(&v))
+}
+
+// IoctlSlice runs request with a pointer to the first element of args, such
+// as SPI_IOC_MESSAGE(n) with n transfers.
+func IoctlSlice[T any](fd, request uintptr, args []T) error {
+	if len(args) == 0 {
+		return ErrIoctlSize
+	}
+	if err := checkIoctl(request, 0, uintptr(len(args))*unsafe.Sizeof(args[0])); err != nil {
+		return err
+	}
+	err := ioctlPtr(fd, request, unsafe.Pointer(&args[0]))
+	runtime.KeepAlive(args)
+	return err
+}
+
+// IoctlBuffer runs a request whose argument is a pointer to a buffer sized by
+// its contents rather than by the request number, such as the vcio
+// _IOWR(100, 0, char *), with a pointer to the first element of buf.
+func IoctlBuffer[T any](fd, request uintptr, buf []T) error {
+	if len(buf) == 0 {
+		return ErrIoctlSize
+	}
+	if err := checkIoctl(request, 0, unsafe.Sizeof(uintptr(0))); err != nil {
+		return err
+	}
+	err := ioctlPtr(fd, request, unsafe.Pointer(&buf[0]))
+	runtime.KeepAlive(buf)
+	return err
+}
+
+// isLegacyIoctl tells whether request is an _IO number, or a legacy one
+// predating the direction and size fields, e.g. 0x5409 for TCSBRK.
+func isLegacyIoctl(request uintptr) bool {
+	return IOC_SIZE(request) == 0 && IOC_DIR(request)&(IOC_READ|IOC_WRITE) == 0
+}
+
+// IoctlValue runs an _IO request, or a legacy one, taking v by value.
+func IoctlValue(fd, request, v uintptr) error {
+	if !isLegacyIoctl(request) {
+		return ErrIoctlDir
+	}
+	return Ioctl(fd, request, v)
 }
 
 // below copied from
@@ -19,8 +137,6 @@ func Ioctl(fd, request, argp uintptr) error {
 const (
 	IOC_NRBITS    = 8
 	IOC_TYPEBITS  = 8
-	IOC_SIZEBITS  = 14
-	IOC_DIRBITS   = 2
 	IOC_NRMASK    = (1 << IOC_NRBITS) - 1
 	IOC_TYPEMASK  = (1 << IOC_TYPEBITS) - 1
 	IOC_SIZEMASK  = (1 << IOC_SIZEBITS) - 1
@@ -29,10 +145,6 @@ const (
 	IOC_TYPESHIFT = IOC_NRSHIFT + IOC_NRBITS
 	IOC_SIZESHIFT = IOC_TYPESHIFT + IOC_TYPEBITS
 	IOC_DIRSHIFT  = IOC_SIZESHIFT + IOC_SIZEBITS
-	// Direction bits
-	IOC_NONE  = 0
-	IOC_WRITE = 1
-	IOC_READ  = 2
 )
 
 //...and for the drivers/sound files...
@@ -62,6 +174,39 @@ func IOW(t, nr, size uintptr) uintptr {
 func IOWR(t, nr, size uintptr) uintptr {
 	return IOC(IOC_READ|IOC_WRITE, t, nr, size)
 }
+
+// IOROf is IOR with the size of T.
+func IOROf[T any](t, nr uintptr) uintptr {
+	var v T
+	return IOR(t, nr, unsafe.Sizeof(v))
+}
+
+// IOWOf is IOW with the size of T.
+func IOWOf[T any](t, nr uintptr) uintptr {
+	var v T
+	return IOW(t, nr, unsafe.Sizeof(v))
+}
+
+// IOWROf is IOWR with the size of T.
+func IOWROf[T any](t, nr uintptr) uintptr {
+	var v T
+	return IOWR(t, nr, unsafe.Sizeof(v))
+}
+
+// used to decode ioctl numbers
+func IOC_DIR(nr uintptr) uintptr {
+	return (nr >> IOC_DIRSHIFT) & IOC_DIRMASK
+}
+func IOC_TYPE(nr uintptr) uintptr {
+	return (nr >> IOC_TYPESHIFT) & IOC_TYPEMASK
+}
+func IOC_NR(nr uintptr) uintptr {
+	return (nr >> IOC_NRSHIFT) & IOC_NRMASK
+}
+func IOC_SIZE(nr uintptr) uintptr {
+	return (nr >> IOC_SIZESHIFT) & IOC_SIZEMASK
+}
+
 func IOR_BAD(t, nr, size uintptr) uintptr {
 	return IOC(IOC_READ, t, nr, size)
 }
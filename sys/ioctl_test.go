@@ -0,0 +1,97 @@
+//go:build linux
+// +build linux
+
+package sys
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+type ioctlArg struct {
+	a uint32
+	b uint16
+}
+
+func TestIOC(t *testing.T) {
+	if IOC_DIRBITS != 2 {
+		t.Skip("numbers below are those of asm-generic")
+	}
+	// _IOR('k', 4, __u32) from <linux/spi/spidev.h>
+	if nr := IOROf[uint32]('k', 4); nr != 0x80046B04 {
+		t.Errorf("IOROf mismatch: 0x%x", nr)
+	}
+	// TCSETS2 is _IOW('T', 0x2B, struct termios2)
+	if nr := IOWOf[[44]byte]('T', 0x2B); nr != 0x402C542B {
+		t.Errorf("IOWOf mismatch: 0x%x", nr)
+	}
+	nr := IOWROf[ioctlArg](100, 7)
+	if IOC_DIR(nr) != IOC_READ|IOC_WRITE || IOC_TYPE(nr) != 100 || IOC_NR(nr) != 7 || IOC_SIZE(nr) != 8 {
+		t.Errorf("IOC decode mismatch: 0x%x", nr)
+	}
+}
+
+func TestCheckIoctl(t *testing.T) {
+	for _, c := range []struct {
+		request, dir, size uintptr
+		err                error
+	}{
+		{0x0705, IOC_READ, 8, nil}, // legacy I2C_FUNCS
+		{IOROf[uint32]('k', 4), IOC_READ, 4, nil},
+		{IOROf[uint32]('k', 4), 0, 4, nil},
+		{IOROf[uint32]('k', 4), IOC_WRITE, 4, ErrIoctlDir},
+		{IOROf[uint32]('k', 4), IOC_READ, 8, ErrIoctlSize},
+		{IOWROf[uint8]('k', 4), IOC_WRITE, 1, nil},
+		{IOC(IOC_NONE, 'k', 4, 4), 0, 4, ErrIoctlDir},
+		{IO('k', 4), IOC_WRITE, 8, nil},
+	} {
+		if err := checkIoctl(c.request, c.dir, c.size); err != c.err {
+			t.Errorf("0x%x: %v expected, got %v", c.request, c.err, err)
+		}
+	}
+}
+
+func TestIoctlBuffer(t *testing.T) {
+	buf := make([]uint32, 8)
+	if err := IoctlBuffer(^uintptr(0), IOWROf[[32]byte](100, 0), buf); err != ErrIoctlSize {
+		t.Errorf("%v expected, got %v", ErrIoctlSize, err)
+	}
+	if err := IoctlBuffer(^uintptr(0), IOWROf[uintptr](100, 0), buf[:0]); err != ErrIoctlSize {
+		t.Errorf("%v expected, got %v", ErrIoctlSize, err)
+	}
+	if err := IoctlBuffer(^uintptr(0), IOWROf[uintptr](100, 0), buf); err == nil || err == ErrIoctlSize {
+		t.Errorf("EBADF expected, got %v", err)
+	}
+}
+
+func TestIoctlPty(t *testing.T) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer master.Close()
+	fd := master.Fd()
+	// TIOCSPTLCK and TIOCGPTN take an int
+	if err = IoctlWrite(fd, syscall.TIOCSPTLCK, int32(0)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := IoctlRead[uint32](fd, syscall.TIOCGPTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat("/dev/pts/" + strconv.FormatUint(uint64(n), 10)); err != nil {
+		t.Error(err)
+	}
+	if _, err = IoctlRead[uint64](fd, syscall.TIOCGPTN); err != ErrIoctlSize {
+		t.Errorf("%v expected, got %v", ErrIoctlSize, err)
+	}
+	if err = IoctlWrite(fd, syscall.TIOCGPTN, uint32(0)); err != ErrIoctlDir {
+		t.Errorf("%v expected, got %v", ErrIoctlDir, err)
+	}
+	var inq int32 // legacy TIOCINQ
+	if err = IoctlPtr(fd, syscall.TIOCINQ, &inq); err != nil || inq != 0 {
+		t.Errorf("TIOCINQ mismatch: %d %v", inq, err)
+	}
+}
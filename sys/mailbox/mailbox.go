@@ -0,0 +1,93 @@
+// Package mailbox speaks the property tag protocol of the VideoCore firmware
+// through /dev/vcio.
+package mailbox
+
+import (
+	"os"
+
+	"github.com/zyxar/berry/sys"
+	"github.com/zyxar/berry/sys/internal/property"
+)
+
+const DEV_VCIO = "/dev/vcio"
+
+const (
+	PROCESS_REQUEST  = property.PROCESS_REQUEST
+	RESPONSE_SUCCESS = property.RESPONSE_SUCCESS
+	RESPONSE_ERROR   = property.RESPONSE_ERROR
+	TAG_RESPONSE     = property.TAG_RESPONSE // set in the code of an answered tag
+	TAG_END          = property.TAG_END
+)
+
+var (
+	ErrFirmware    = property.ErrFirmware
+	ErrMessageSize = property.ErrMessageSize
+	errMalformed   = property.ErrMalformed
+)
+
+// IOCTL_MBOX_PROPERTY is _IOWR(100, 0, char *) from the vcio driver.
+var IOCTL_MBOX_PROPERTY = sys.IOWROf[uintptr](100, 0)
+
+// Tag is a property tag. Size is the value buffer size, which must hold both
+// the request and the response; the firmware writes at most Size bytes back.
+// Response is set by Property.
+type Tag = property.Tag
+
+// NotAnsweredError reports a tag the firmware did not process, often unknown
+// to its version.
+type NotAnsweredError = property.NotAnsweredError
+
+// Mailbox is an open property channel to the firmware.
+type Mailbox struct {
+	file *os.File
+	do   func(buf []byte) error // exchanges a message in place
+}
+
+// Open opens DEV_VCIO.
+func Open() (*Mailbox, error) {
+	return OpenDevice(DEV_VCIO)
+}
+
+// OpenDevice opens the vcio device at path.
+func OpenDevice(path string) (*Mailbox, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mailbox{file: file}
+	m.do = func(buf []byte) error {
+		return sys.IoctlBuffer(file.Fd(), IOCTL_MBOX_PROPERTY, buf)
+	}
+	return m, nil
+}
+
+func (this *Mailbox) Close() error {
+	if this.file == nil {
+		return nil
+	}
+	err := this.file.Close()
+	this.file = nil
+	return err
+}
+
+// Property sends tags in one message and sets their responses.
+func (this *Mailbox) Property(tags ...*Tag) error {
+	buf, err := Encode(tags...)
+	if err != nil {
+		return err
+	}
+	if err = this.do(buf); err != nil {
+		return err
+	}
+	return Decode(buf, tags...)
+}
+
+// Encode builds the message carrying tags, all values little endian.
+func Encode(tags ...*Tag) ([]byte, error) {
+	return property.Encode(tags...)
+}
+
+// Decode sets the responses of tags from the message buf they were encoded in.
+func Decode(buf []byte, tags ...*Tag) error {
+	return property.Decode(buf, tags...)
+}
@@ -0,0 +1,129 @@
+package mailbox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/zyxar/berry/sys"
+)
+
+func TestEncode(t *testing.T) {
+	buf, err := Encode(
+		&Tag{ID: TAG_GET_BOARD_MAC_ADDRESS, Size: 6},
+		&Tag{ID: TAG_GET_CLOCK_RATE, Request: []byte{CLOCK_ARM, 0, 0, 0}, Size: 8},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint32{
+		0x34, PROCESS_REQUEST,
+		TAG_GET_BOARD_MAC_ADDRESS, 6, 0, 0, 0,
+		TAG_GET_CLOCK_RATE, 8, 4, CLOCK_ARM, 0,
+		TAG_END,
+	}
+	if len(buf) != 4*len(want) {
+		t.Fatalf("size mismatch: %d", len(buf))
+	}
+	for i, w := range want {
+		if v := binary.LittleEndian.Uint32(buf[4*i:]); v != w {
+			t.Errorf("word %d mismatch: 0x%08x", i, v)
+		}
+	}
+}
+
+// firmware answers a message in place like the VideoCore does.
+type firmware map[uint32]func(req []uint32) []uint32
+
+func (this firmware) do(buf []byte) error {
+	le := binary.LittleEndian
+	le.PutUint32(buf[4:], RESPONSE_SUCCESS)
+	for at := 8; le.Uint32(buf[at:]) != TAG_END; {
+		id, size := le.Uint32(buf[at:]), int(le.Uint32(buf[at+4:]))
+		if f, ok := this[id]; ok {
+			req := make([]uint32, size/4)
+			for i := range req {
+				req[i] = le.Uint32(buf[at+12+4*i:])
+			}
+			resp := f(req)
+			for i, w := range resp {
+				if 4*i < size {
+					le.PutUint32(buf[at+12+4*i:], w)
+				}
+			}
+			le.PutUint32(buf[at+8:], TAG_RESPONSE|uint32(4*len(resp)))
+		}
+		at += 12 + (size+3)&^3
+	}
+	return nil
+}
+
+func TestHelpers(t *testing.T) {
+	fw := firmware{
+		TAG_GET_BOARD_SERIAL: func([]uint32) []uint32 { return []uint32{0x2a3b4c5d, 0x10000000} },
+		TAG_GET_BOARD_MAC_ADDRESS: func([]uint32) []uint32 {
+			return []uint32{0x3AEB27DC, 0x0000B2A6} // dc:a6:32:... in memory order
+		},
+		TAG_GET_VC_MEMORY: func([]uint32) []uint32 { return []uint32{0x3C000000, 0x04000000} },
+		TAG_GET_CLOCK_RATE: func(req []uint32) []uint32 {
+			if req[0] == CLOCK_ARM {
+				return []uint32{req[0], 1500000000}
+			}
+			return []uint32{req[0], 0}
+		},
+		TAG_GET_THROTTLED: func([]uint32) []uint32 { return []uint32{0x50005} },
+		TAG_GET_POWER_STATE: func(req []uint32) []uint32 {
+			if req[0] == POWER_CCP2TX {
+				return []uint32{req[0], 2}
+			}
+			return []uint32{req[0], 1}
+		},
+	}
+	m := &Mailbox{do: fw.do}
+	if serial, err := m.BoardSerial(); err != nil || serial != 0x100000002a3b4c5d {
+		t.Errorf("serial mismatch: %x, %v", serial, err)
+	}
+	if mac, err := m.MACAddress(); err != nil || !bytes.Equal(mac, []byte{0xDC, 0x27, 0xEB, 0x3A, 0xA6, 0xB2}) {
+		t.Errorf("mac mismatch: %v, %v", mac, err)
+	}
+	if base, size, err := m.VCMemory(); err != nil || base != 0x3C000000 || size != 64<<20 {
+		t.Errorf("vc memory mismatch: %x %x, %v", base, size, err)
+	}
+	if hz, err := m.ClockRate(CLOCK_ARM); err != nil || hz != 1500000000 {
+		t.Errorf("clock rate mismatch: %d, %v", hz, err)
+	}
+	if v, err := m.Throttled(); err != nil || v != sys.THROTTLED_UNDER_VOLTAGE|sys.THROTTLED_THROTTLED|
+		sys.THROTTLED_UNDER_VOLTAGE_OCCUR|sys.THROTTLED_THROTTLED_OCCUR {
+		t.Errorf("throttled mismatch: %v, %v", v, err)
+	}
+	if on, exists, err := m.PowerState(POWER_USB_HCD); err != nil || !on || !exists {
+		t.Errorf("usb power state mismatch: %v %v, %v", on, exists, err)
+	}
+	if _, exists, err := m.PowerState(POWER_CCP2TX); err != nil || exists {
+		t.Errorf("absent device reported: %v, %v", exists, err)
+	}
+	_, err := m.Temperature()
+	if e, ok := err.(*NotAnsweredError); !ok || e.ID != TAG_GET_TEMPERATURE {
+		t.Errorf("unanswered tag accepted: %v", err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tag := &Tag{ID: TAG_GET_BOARD_MODEL, Size: 4}
+	buf, _ := Encode(tag)
+	if err := Decode(buf, tag); err != errMalformed {
+		t.Errorf("unprocessed message accepted: %v", err)
+	}
+	binary.LittleEndian.PutUint32(buf[4:], RESPONSE_ERROR)
+	if err := Decode(buf, tag); err != ErrFirmware {
+		t.Errorf("firmware error not reported: %v", err)
+	}
+	binary.LittleEndian.PutUint32(buf[4:], RESPONSE_SUCCESS)
+	binary.LittleEndian.PutUint32(buf[16:], TAG_RESPONSE|8) // longer than the buffer
+	if err := Decode(buf, tag); err != nil || len(tag.Response) != 4 {
+		t.Errorf("truncated response mismatch: %v, %v", tag.Response, err)
+	}
+	if err := Decode(buf[:len(buf)-4], tag); err != errMalformed {
+		t.Errorf("short message accepted: %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package mailbox
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/zyxar/berry/sys"
+)
+
+const (
+	TAG_GET_FIRMWARE_REVISION   = 0x00000001
+	TAG_GET_BOARD_MODEL         = 0x00010001
+	TAG_GET_BOARD_REVISION      = 0x00010002
+	TAG_GET_BOARD_MAC_ADDRESS   = 0x00010003
+	TAG_GET_BOARD_SERIAL        = 0x00010004
+	TAG_GET_ARM_MEMORY          = 0x00010005
+	TAG_GET_VC_MEMORY           = 0x00010006
+	TAG_GET_POWER_STATE         = 0x00020001
+	TAG_SET_POWER_STATE         = 0x00028001
+	TAG_GET_CLOCK_RATE          = 0x00030002
+	TAG_GET_VOLTAGE             = 0x00030003
+	TAG_GET_MAX_CLOCK_RATE      = 0x00030004
+	TAG_GET_MAX_VOLTAGE         = 0x00030005
+	TAG_GET_TEMPERATURE         = 0x00030006
+	TAG_GET_MIN_CLOCK_RATE      = 0x00030007
+	TAG_GET_MIN_VOLTAGE         = 0x00030008
+	TAG_GET_MAX_TEMPERATURE     = 0x0003000A
+	TAG_GET_THROTTLED           = 0x00030046
+	TAG_GET_CLOCK_RATE_MEASURED = 0x00030047
+) // from <soc/bcm2835/raspberrypi-firmware.h>
+
+const (
+	CLOCK_EMMC      = 1
+	CLOCK_UART      = 2
+	CLOCK_ARM       = 3
+	CLOCK_CORE      = 4
+	CLOCK_V3D       = 5
+	CLOCK_H264      = 6
+	CLOCK_ISP       = 7
+	CLOCK_SDRAM     = 8
+	CLOCK_PIXEL     = 9
+	CLOCK_PWM       = 10
+	CLOCK_HEVC      = 11
+	CLOCK_EMMC2     = 12
+	CLOCK_M2MC      = 13
+	CLOCK_PIXEL_BVB = 14
+)
+
+const (
+	VOLTAGE_CORE    = 1
+	VOLTAGE_SDRAM_C = 2
+	VOLTAGE_SDRAM_P = 3
+	VOLTAGE_SDRAM_I = 4
+)
+
+const (
+	POWER_SD_CARD = 0
+	POWER_UART0   = 1
+	POWER_UART1   = 2
+	POWER_USB_HCD = 3
+	POWER_I2C0    = 4
+	POWER_I2C1    = 5
+	POWER_I2C2    = 6
+	POWER_SPI     = 7
+	POWER_CCP2TX  = 8
+)
+
+const (
+	powerOn     = 1 << 0
+	powerWait   = 1 << 1
+	powerAbsent = 1 << 1 // in responses
+)
+
+// words sends a single tag with the request words and returns the response
+// words, of which there must be at least n.
+func (this *Mailbox) words(id uint32, n int, req ...uint32) (resp []uint32, err error) {
+	tag := &Tag{ID: id, Request: make([]byte, 4*len(req)), Size: 4 * n}
+	for i, w := range req {
+		binary.LittleEndian.PutUint32(tag.Request[4*i:], w)
+	}
+	if err = this.Property(tag); err != nil {
+		return
+	}
+	if len(tag.Response) < 4*n {
+		err = errMalformed
+		return
+	}
+	resp = make([]uint32, n)
+	for i := range resp {
+		resp[i] = binary.LittleEndian.Uint32(tag.Response[4*i:])
+	}
+	return
+}
+
+func (this *Mailbox) word(id uint32) (uint32, error) {
+	resp, err := this.words(id, 1)
+	if err != nil {
+		return 0, err
+	}
+	return resp[0], nil
+}
+
+// FirmwareRevision returns the firmware build time, in seconds since the epoch.
+func (this *Mailbox) FirmwareRevision() (uint32, error) {
+	return this.word(TAG_GET_FIRMWARE_REVISION)
+}
+
+func (this *Mailbox) BoardModel() (uint32, error) {
+	return this.word(TAG_GET_BOARD_MODEL)
+}
+
+// BoardRevision returns the revision code also found in /proc/cpuinfo.
+func (this *Mailbox) BoardRevision() (uint32, error) {
+	return this.word(TAG_GET_BOARD_REVISION)
+}
+
+func (this *Mailbox) BoardSerial() (uint64, error) {
+	resp, err := this.words(TAG_GET_BOARD_SERIAL, 2)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(resp[1])<<32 | uint64(resp[0]), nil
+}
+
+// MACAddress returns the MAC address of the on-board Ethernet.
+func (this *Mailbox) MACAddress() (net.HardwareAddr, error) {
+	tag := &Tag{ID: TAG_GET_BOARD_MAC_ADDRESS, Size: 6}
+	if err := this.Property(tag); err != nil {
+		return nil, err
+	}
+	if len(tag.Response) < 6 {
+		return nil, errMalformed
+	}
+	return net.HardwareAddr(tag.Response[:6]), nil
+}
+
+// ARMMemory returns the memory split given to the ARM cores, in bytes.
+func (this *Mailbox) ARMMemory() (base, size uint32, err error) {
+	resp, err := this.words(TAG_GET_ARM_MEMORY, 2)
+	if err != nil {
+		return
+	}
+	return resp[0], resp[1], nil
+}
+
+// VCMemory returns the memory split given to the GPU, in bytes.
+func (this *Mailbox) VCMemory() (base, size uint32, err error) {
+	resp, err := this.words(TAG_GET_VC_MEMORY, 2)
+	if err != nil {
+		return
+	}
+	return resp[0], resp[1], nil
+}
+
+// PowerState returns whether device is powered, and whether it exists.
+func (this *Mailbox) PowerState(device uint32) (on, exists bool, err error) {
+	resp, err := this.words(TAG_GET_POWER_STATE, 2, device)
+	if err != nil {
+		return
+	}
+	return resp[1]&powerOn != 0, resp[1]&powerAbsent == 0, nil
+}
+
+// SetPowerState powers device on or off, optionally waiting for it to become
+// stable, and returns its new state.
+func (this *Mailbox) SetPowerState(device uint32, on, wait bool) (bool, error) {
+	var state uint32
+	if on {
+		state |= powerOn
+	}
+	if wait {
+		state |= powerWait
+	}
+	resp, err := this.words(TAG_SET_POWER_STATE, 2, device, state)
+	if err != nil {
+		return false, err
+	}
+	if resp[1]&powerAbsent != 0 {
+		return false, &NotAnsweredError{ID: TAG_SET_POWER_STATE}
+	}
+	return resp[1]&powerOn != 0, nil
+}
+
+// value sends a tag taking one word, such as a clock id, and returns the
+// second response word.
+func (this *Mailbox) value(id, arg uint32) (uint32, error) {
+	resp, err := this.words(id, 2, arg)
+	if err != nil {
+		return 0, err
+	}
+	return resp[1], nil
+}
+
+// ClockRate returns the rate clock is set to, in Hz.
+func (this *Mailbox) ClockRate(clock uint32) (uint32, error) {
+	return this.value(TAG_GET_CLOCK_RATE, clock)
+}
+
+// MeasuredClockRate returns the rate clock actually runs at, in Hz.
+func (this *Mailbox) MeasuredClockRate(clock uint32) (uint32, error) {
+	return this.value(TAG_GET_CLOCK_RATE_MEASURED, clock)
+}
+
+func (this *Mailbox) MaxClockRate(clock uint32) (uint32, error) {
+	return this.value(TAG_GET_MAX_CLOCK_RATE, clock)
+}
+
+func (this *Mailbox) MinClockRate(clock uint32) (uint32, error) {
+	return this.value(TAG_GET_MIN_CLOCK_RATE, clock)
+}
+
+// Voltage returns the voltage of id, in microvolts.
+func (this *Mailbox) Voltage(id uint32) (uint32, error) {
+	return this.value(TAG_GET_VOLTAGE, id)
+}
+
+func (this *Mailbox) MaxVoltage(id uint32) (uint32, error) {
+	return this.value(TAG_GET_MAX_VOLTAGE, id)
+}
+
+func (this *Mailbox) MinVoltage(id uint32) (uint32, error) {
+	return this.value(TAG_GET_MIN_VOLTAGE, id)
+}
+
+// Temperature returns the SoC temperature, in thousandths of a degree Celsius.
+func (this *Mailbox) Temperature() (uint32, error) {
+	return this.value(TAG_GET_TEMPERATURE, 0)
+}
+
+// MaxTemperature returns the temperature at which the SoC is throttled.
+func (this *Mailbox) MaxTemperature() (uint32, error) {
+	return this.value(TAG_GET_MAX_TEMPERATURE, 0)
+}
+
+func (this *Mailbox) Throttled() (sys.Throttled, error) {
+	resp, err := this.words(TAG_GET_THROTTLED, 1, 0)
+	if err != nil {
+		return 0, err
+	}
+	return sys.Throttled(resp[0]), nil
+}
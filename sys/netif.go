@@ -0,0 +1,276 @@
+//go:build linux
+// +build linux
+
+package sys
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	SIOCGIWESSID   = 0x8B1B // from <linux/wireless.h>
+	IW_ESSID_MAX   = 32
+	RTF_UP         = 0x0001 // from <linux/route.h>
+	RTF_GATEWAY    = 0x0002
+	NET_SPEED_NONE = -1
+)
+
+// NetCounters are the traffic counters of an interface in one direction.
+type NetCounters struct {
+	Bytes   uint64
+	Packets uint64
+	Errors  uint64
+	Dropped uint64
+}
+
+// Wireless is the state of a wireless interface. Quality, Level and Noise are
+// zero if the interface is not associated.
+type Wireless struct {
+	SSID    string
+	Quality float64 // link quality, driver specific scale
+	Level   float64 // signal level, dBm
+	Noise   float64 // noise level, dBm
+}
+
+// NetInterface is the status of a network interface.
+type NetInterface struct {
+	Name      string
+	Index     int
+	MTU       int
+	MAC       net.HardwareAddr
+	Flags     net.Flags
+	Addrs     []*net.IPNet
+	OperState string // e.g. up, down, dormant or unknown for loopback
+	Carrier   bool
+	Speed     int    // Mb/s, NET_SPEED_NONE if unknown
+	Gateway   net.IP // of the IPv4 default route through this interface, 0.0.0.0 if direct
+	Metric    uint32 // of the default route
+	RX, TX    NetCounters
+	Wireless  *Wireless // nil for wired interfaces
+}
+
+// GetNetInterfaces returns the status of every network interface.
+func GetNetInterfaces() (ifaces []NetInterface, err error) {
+	list, err := net.Interfaces()
+	if err != nil {
+		return
+	}
+	for _, i := range list {
+		n := NetInterface{Name: i.Name, Index: i.Index, MTU: i.MTU, MAC: i.HardwareAddr, Flags: i.Flags}
+		addrs, _ := i.Addrs()
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok {
+				n.Addrs = append(n.Addrs, ipnet)
+			}
+		}
+		ifaces = append(ifaces, n)
+	}
+	if err = readNetStatus("/", ifaces); err != nil {
+		return
+	}
+	for i := range ifaces {
+		if w := ifaces[i].Wireless; w != nil {
+			w.SSID, _ = wirelessSSID(ifaces[i].Name)
+		}
+	}
+	return
+}
+
+// PrimaryAddr returns the address the system is most likely reached at, see
+// Primary, without needing a route to the outside.
+func PrimaryAddr() (net.IP, error) {
+	ifaces, err := GetNetInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	if _, ip := Primary(ifaces); ip != nil {
+		return ip, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// Primary picks the primary interface and address of ifaces: global over
+// link-local over loopback addresses, then interfaces with a default route
+// (the lowest metric wins), then those with carrier, then IPv4 over IPv6.
+// Interfaces that are down are skipped.
+func Primary(ifaces []NetInterface) (iface *NetInterface, ip net.IP) {
+	best := -1
+	for i := range ifaces {
+		n := &ifaces[i]
+		if n.Flags&net.FlagUp == 0 {
+			continue
+		}
+		for _, a := range n.Addrs {
+			score := addrScore(n, a.IP)
+			if score < 0 || score < best {
+				continue
+			}
+			if score == best && (n.Gateway == nil || n.Metric >= iface.Metric) {
+				continue
+			}
+			best, iface, ip = score, n, a.IP
+		}
+	}
+	return
+}
+
+func addrScore(n *NetInterface, ip net.IP) (score int) {
+	switch {
+	case ip.IsGlobalUnicast():
+		score = 3
+	case ip.IsLinkLocalUnicast():
+		score = 2
+	case ip.IsLoopback():
+		score = 1
+	default:
+		return -1
+	}
+	for _, b := range []bool{n.Gateway != nil, n.Carrier, ip.To4() != nil} {
+		score <<= 1
+		if b {
+			score |= 1
+		}
+	}
+	return
+}
+
+// readNetStatus fills the state, counters, default routes and wireless
+// statistics of ifaces from the procfs and sysfs below root.
+func readNetStatus(root string, ifaces []NetInterface) error {
+	byName := make(map[string]*NetInterface, len(ifaces))
+	for i := range ifaces {
+		n := &ifaces[i]
+		byName[n.Name] = n
+		dir := filepath.Join(root, "sys/class/net", n.Name)
+		n.OperState = readString(filepath.Join(dir, "operstate"))
+		n.Carrier = readString(filepath.Join(dir, "carrier")) == "1"
+		n.Speed = NET_SPEED_NONE
+		if v, err := strconv.Atoi(readString(filepath.Join(dir, "speed"))); err == nil && v >= 0 {
+			n.Speed = v
+		}
+		if _, err := os.Stat(filepath.Join(dir, "wireless")); err == nil {
+			n.Wireless = &Wireless{}
+		}
+	}
+	if err := scanNetFile(filepath.Join(root, "proc/net/dev"), 2, func(name string, fields []string) {
+		if n := byName[name]; n != nil && len(fields) >= 12 {
+			n.RX = netCounters(fields[0:4])
+			n.TX = netCounters(fields[8:12])
+		}
+	}); err != nil {
+		return err
+	}
+	scanNetFile(filepath.Join(root, "proc/net/wireless"), 2, func(name string, fields []string) {
+		n := byName[name]
+		if n == nil || len(fields) < 4 {
+			return
+		}
+		if n.Wireless == nil {
+			n.Wireless = &Wireless{}
+		}
+		n.Wireless.Quality = parseLevel(fields[1])
+		n.Wireless.Level = parseLevel(fields[2])
+		n.Wireless.Noise = parseLevel(fields[3])
+	})
+	scanNetFile(filepath.Join(root, "proc/net/route"), 1, func(name string, fields []string) {
+		// Destination Gateway Flags RefCnt Use Metric Mask ...
+		n := byName[name]
+		if n == nil || len(fields) < 7 || fields[0] != "00000000" || fields[6] != "00000000" {
+			return
+		}
+		flags, _ := strconv.ParseUint(fields[2], 16, 16)
+		if flags&RTF_UP == 0 {
+			return
+		}
+		metric, _ := strconv.ParseUint(fields[5], 10, 32)
+		if n.Gateway != nil && uint32(metric) >= n.Metric {
+			return
+		}
+		n.Gateway, n.Metric = net.IPv4zero, uint32(metric)
+		if gw, err := strconv.ParseUint(fields[1], 16, 32); err == nil && flags&RTF_GATEWAY != 0 {
+			n.Gateway = make(net.IP, 4)
+			binary.LittleEndian.PutUint32(n.Gateway, uint32(gw))
+		}
+	})
+	return nil
+}
+
+// scanNetFile calls fn with the interface name and the other fields of each
+// line of a /proc/net table, skipping header lines. Names may be followed by
+// a colon, glued to the first field.
+func scanNetFile(path string, header int, fn func(name string, fields []string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for line := 0; scanner.Scan(); line++ {
+		if line < header {
+			continue
+		}
+		text := scanner.Text()
+		var name string
+		if i := strings.IndexByte(text, ':'); i >= 0 {
+			name, text = strings.TrimSpace(text[:i]), text[i+1:]
+		} else if fields := strings.Fields(text); len(fields) > 0 {
+			name, text = fields[0], strings.Join(fields[1:], " ")
+		}
+		fn(name, strings.Fields(text))
+	}
+	return scanner.Err()
+}
+
+// netCounters parses the bytes, packets, errs and drop columns of fields.
+func netCounters(fields []string) (c NetCounters) {
+	c.Bytes, _ = strconv.ParseUint(fields[0], 10, 64)
+	c.Packets, _ = strconv.ParseUint(fields[1], 10, 64)
+	c.Errors, _ = strconv.ParseUint(fields[2], 10, 64)
+	c.Dropped, _ = strconv.ParseUint(fields[3], 10, 64)
+	return
+}
+
+// parseLevel parses a /proc/net/wireless value such as "70." or "-40.".
+func parseLevel(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSuffix(s, "."), 64)
+	return v
+}
+
+// iwreq is struct iwreq from <linux/wireless.h>, with the iw_point member of
+// its union.
+type iwreq struct {
+	name    [syscall.IFNAMSIZ]byte
+	pointer unsafe.Pointer
+	length  uint16
+	flags   uint16
+	_       [12 - unsafe.Sizeof(uintptr(0))]byte
+}
+
+// wirelessSSID asks the wireless extensions for the ESSID of name.
+func wirelessSSID(name string) (ssid string, err error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return
+	}
+	defer syscall.Close(fd)
+	var buf [IW_ESSID_MAX + 1]byte
+	req := iwreq{pointer: unsafe.Pointer(&buf[0]), length: uint16(len(buf))}
+	copy(req.name[:syscall.IFNAMSIZ-1], name)
+	if err = IoctlPtr(uintptr(fd), SIOCGIWESSID, &req); err != nil {
+		return
+	}
+	n := int(req.length)
+	if n > IW_ESSID_MAX {
+		n = IW_ESSID_MAX
+	}
+	ssid = strings.TrimRight(string(buf[:n]), "\x00")
+	return
+}
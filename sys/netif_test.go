@@ -0,0 +1,125 @@
+//go:build linux
+// +build linux
+
+package sys
+
+import (
+	"net"
+	"testing"
+	"unsafe"
+)
+
+func ipnet(s string) *net.IPNet {
+	ip, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	n.IP = ip
+	return n
+}
+
+func TestReadNetStatus(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"sys/class/net/lo/operstate":      "unknown\n",
+		"sys/class/net/lo/carrier":        "1\n",
+		"sys/class/net/eth0/operstate":    "up\n",
+		"sys/class/net/eth0/carrier":      "1\n",
+		"sys/class/net/eth0/speed":        "1000\n",
+		"sys/class/net/wlan0/operstate":   "up\n",
+		"sys/class/net/wlan0/carrier":     "1\n",
+		"sys/class/net/wlan0/speed":       "-1\n",
+		"sys/class/net/wlan0/wireless/.x": "",
+		"proc/net/dev": `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:   12345      67    0    0    0     0          0         0    12345      67    0    0    0     0       0          0
+  eth0: 98765432  123456    1    2    0     0          0       100 1234567    8901    3    4    0     0       0          0
+ wlan0:  555000    4000    0   17    0     0          0         0   222000    1500    0    0    0     0       0          0
+`,
+		"proc/net/wireless": `Inter-| sta-|   Quality        |   Discarded packets               | Missed | WE
+ face | tus | link level noise |  nwid  crypt   frag  retry   misc | beacon | 22
+ wlan0: 0000   58.  -52.  -256        0      0      0      0     12        0
+`,
+		"proc/net/route": "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+			"wlan0\t00000000\t0101A8C0\t0003\t0\t0\t303\t00000000\t0\t0\t0\n" +
+			"eth0\t00000000\t010010AC\t0003\t0\t0\t202\t00000000\t0\t0\t0\n" +
+			"eth0\t00000000\t020010AC\t0003\t0\t0\t400\t00000000\t0\t0\t0\n" +
+			"eth0\t000010AC\t00000000\t0001\t0\t0\t202\t0000FFFF\t0\t0\t0\n",
+	})
+	ifaces := []NetInterface{{Name: "lo"}, {Name: "eth0"}, {Name: "wlan0"}, {Name: "usb0"}}
+	if err := readNetStatus(root, ifaces); err != nil {
+		t.Fatal(err)
+	}
+	lo, eth, wlan, usb := ifaces[0], ifaces[1], ifaces[2], ifaces[3]
+	if lo.OperState != "unknown" || lo.Gateway != nil || lo.Wireless != nil || lo.RX.Bytes != 12345 || lo.TX.Packets != 67 {
+		t.Errorf("lo mismatch: %+v", lo)
+	}
+	if !eth.Carrier || eth.Speed != 1000 || !eth.Gateway.Equal(net.IPv4(172, 16, 0, 1)) || eth.Metric != 202 ||
+		eth.RX != (NetCounters{98765432, 123456, 1, 2}) || eth.TX != (NetCounters{1234567, 8901, 3, 4}) {
+		t.Errorf("eth0 mismatch: %+v", eth)
+	}
+	if wlan.Speed != NET_SPEED_NONE || !wlan.Gateway.Equal(net.IPv4(192, 168, 1, 1)) || wlan.RX.Dropped != 17 ||
+		wlan.Wireless == nil || *wlan.Wireless != (Wireless{Quality: 58, Level: -52, Noise: -256}) {
+		t.Errorf("wlan0 mismatch: %+v %+v", wlan, wlan.Wireless)
+	}
+	if usb.OperState != "" || usb.Speed != NET_SPEED_NONE || usb.RX.Bytes != 0 {
+		t.Errorf("usb0 mismatch: %+v", usb)
+	}
+}
+
+func TestPrimary(t *testing.T) {
+	up := net.FlagUp
+	lo := NetInterface{Name: "lo", Flags: up | net.FlagLoopback, Addrs: []*net.IPNet{ipnet("127.0.0.1/8"), ipnet("::1/128")}}
+	// an offline site: static address, no default route
+	eth := NetInterface{Name: "eth0", Flags: up, Carrier: true, Addrs: []*net.IPNet{ipnet("fe80::1/64"), ipnet("10.0.0.5/24")}}
+	wlan := NetInterface{Name: "wlan0", Flags: up, Carrier: true, Gateway: net.IPv4(192, 168, 1, 1), Metric: 303,
+		Addrs: []*net.IPNet{ipnet("192.168.1.20/24")}}
+	usb := NetInterface{Name: "usb0", Flags: up, Carrier: true, Gateway: net.IPv4(192, 168, 7, 1), Metric: 100,
+		Addrs: []*net.IPNet{ipnet("192.168.7.2/24")}}
+	down := NetInterface{Name: "eth1", Gateway: net.IPv4zero, Addrs: []*net.IPNet{ipnet("10.1.0.5/24")}}
+	ll := NetInterface{Name: "eth2", Flags: up, Carrier: true, Addrs: []*net.IPNet{ipnet("169.254.3.4/16")}}
+	for _, c := range []struct {
+		ifaces []NetInterface
+		name   string
+		ip     string
+	}{
+		{[]NetInterface{lo}, "lo", "127.0.0.1"},
+		{[]NetInterface{lo, ll}, "eth2", "169.254.3.4"},
+		{[]NetInterface{lo, eth, down}, "eth0", "10.0.0.5"},
+		{[]NetInterface{lo, eth, wlan}, "wlan0", "192.168.1.20"},
+		{[]NetInterface{lo, wlan, usb, eth}, "usb0", "192.168.7.2"},
+		{[]NetInterface{down}, "", "<nil>"},
+	} {
+		iface, ip := Primary(c.ifaces)
+		var name string
+		if iface != nil {
+			name = iface.Name
+		}
+		if name != c.name || ip.String() != c.ip {
+			t.Errorf("%s %s expected, got %s %s", c.name, c.ip, name, ip)
+		}
+	}
+}
+
+func TestIwreq(t *testing.T) {
+	var req iwreq
+	if size := unsafe.Sizeof(req); size != 32 {
+		t.Errorf("iwreq size mismatch: %d", size)
+	}
+	if off := unsafe.Offsetof(req.length); off != 16+unsafe.Sizeof(uintptr(0)) {
+		t.Errorf("iwreq length offset mismatch: %d", off)
+	}
+}
+
+func TestGetNetInterfaces(t *testing.T) {
+	ifaces, err := GetNetInterfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	if len(ifaces) == 0 {
+		t.Fatal("no interface")
+	}
+	if _, ip := Primary(ifaces); ip == nil {
+		t.Error("no primary address")
+	}
+}
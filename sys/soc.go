@@ -0,0 +1,215 @@
+package sys
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zyxar/berry/sys/internal/property"
+)
+
+// Throttled is the state reported by the firmware's get_throttled.
+type Throttled uint32
+
+const (
+	THROTTLED_UNDER_VOLTAGE       Throttled = 1 << 0
+	THROTTLED_FREQ_CAPPED         Throttled = 1 << 1
+	THROTTLED_THROTTLED           Throttled = 1 << 2
+	THROTTLED_SOFT_TEMP_LIMIT     Throttled = 1 << 3
+	THROTTLED_UNDER_VOLTAGE_OCCUR Throttled = 1 << 16
+	THROTTLED_FREQ_CAPPED_OCCUR   Throttled = 1 << 17
+	THROTTLED_THROTTLED_OCCUR     Throttled = 1 << 18
+	THROTTLED_SOFT_TEMP_OCCUR     Throttled = 1 << 19
+)
+
+var throttledNames = []struct {
+	flag Throttled
+	name string
+}{
+	{THROTTLED_UNDER_VOLTAGE, "under-voltage"},
+	{THROTTLED_FREQ_CAPPED, "freq-capped"},
+	{THROTTLED_THROTTLED, "throttled"},
+	{THROTTLED_SOFT_TEMP_LIMIT, "soft-temp-limit"},
+	{THROTTLED_UNDER_VOLTAGE_OCCUR, "under-voltage-occurred"},
+	{THROTTLED_FREQ_CAPPED_OCCUR, "freq-capped-occurred"},
+	{THROTTLED_THROTTLED_OCCUR, "throttled-occurred"},
+	{THROTTLED_SOFT_TEMP_OCCUR, "soft-temp-limit-occurred"},
+}
+
+func (this Throttled) String() string {
+	var names []string
+	for _, n := range throttledNames {
+		if this&n.flag != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "ok"
+	}
+	return strings.Join(names, ",")
+}
+
+// ThermalZone is a sensor in /sys/class/thermal.
+type ThermalZone struct {
+	Name string  // e.g. thermal_zone0
+	Type string  // e.g. cpu-thermal
+	Temp float64 // degrees Celsius
+}
+
+// CPUFreq is the cpufreq state of a CPU, in kHz.
+type CPUFreq struct {
+	CPU      uint
+	Cur      uint64
+	Min      uint64
+	Max      uint64
+	Governor string
+}
+
+// SoCInfo is a snapshot of the SoC health. Sources missing on the system are
+// left empty.
+type SoCInfo struct {
+	Zones           []ThermalZone
+	Freqs           []CPUFreq
+	Throttled       Throttled
+	ThrottledSource string // "mailbox", "sysfs", "hwmon" or "" if unavailable
+}
+
+// Temperature returns the temperature of the first thermal zone, usually the
+// SoC, and false if there is none.
+func (this SoCInfo) Temperature() (float64, bool) {
+	if len(this.Zones) == 0 {
+		return 0, false
+	}
+	return this.Zones[0].Temp, true
+}
+
+// GetSoCInfo reads the SoC health of the running system.
+func GetSoCInfo() (SoCInfo, error) {
+	return ReadSoCInfo("/")
+}
+
+// ReadSoCInfo reads the SoC health from the sysfs and /dev below root. It
+// fails only if no source is found.
+func ReadSoCInfo(root string) (info SoCInfo, err error) {
+	info.Zones = readThermalZones(root)
+	info.Freqs = readCPUFreqs(root)
+	info.Throttled, info.ThrottledSource = readThrottled(root)
+	if info.Zones == nil && info.Freqs == nil && info.ThrottledSource == "" {
+		err = os.ErrNotExist
+	}
+	return
+}
+
+func readThermalZones(root string) (zones []ThermalZone) {
+	dirs, _ := filepath.Glob(filepath.Join(root, "sys/class/thermal/thermal_zone[0-9]*"))
+	sort.Slice(dirs, func(i, j int) bool { return suffixNumber(dirs[i]) < suffixNumber(dirs[j]) })
+	for _, dir := range dirs {
+		milli, err := strconv.ParseInt(readString(filepath.Join(dir, "temp")), 10, 64)
+		if err != nil {
+			continue
+		}
+		zones = append(zones, ThermalZone{
+			Name: filepath.Base(dir),
+			Type: readString(filepath.Join(dir, "type")),
+			Temp: float64(milli) / 1000,
+		})
+	}
+	return
+}
+
+func readCPUFreqs(root string) (freqs []CPUFreq) {
+	dirs, _ := filepath.Glob(filepath.Join(root, "sys/devices/system/cpu/cpu[0-9]*"))
+	sort.Slice(dirs, func(i, j int) bool { return suffixNumber(dirs[i]) < suffixNumber(dirs[j]) })
+	for _, dir := range dirs {
+		dir = filepath.Join(dir, "cpufreq")
+		cur, err := strconv.ParseUint(readString(filepath.Join(dir, "scaling_cur_freq")), 10, 64)
+		if err != nil {
+			continue
+		}
+		f := CPUFreq{
+			CPU:      uint(suffixNumber(filepath.Dir(dir))),
+			Cur:      cur,
+			Governor: readString(filepath.Join(dir, "scaling_governor")),
+		}
+		f.Min, _ = strconv.ParseUint(readString(filepath.Join(dir, "scaling_min_freq")), 10, 64)
+		f.Max, _ = strconv.ParseUint(readString(filepath.Join(dir, "scaling_max_freq")), 10, 64)
+		freqs = append(freqs, f)
+	}
+	return
+}
+
+// readThrottled asks the firmware mailbox, then the firmware driver's sysfs
+// file, then the under-voltage alarm of the rpi_volt hwmon.
+func readThrottled(root string) (Throttled, string) {
+	if v, err := mailboxThrottled(filepath.Join(root, "dev/vcio")); err == nil {
+		return Throttled(v), "mailbox"
+	}
+	s := readString(filepath.Join(root, "sys/devices/platform/soc/soc:firmware/get_throttled"))
+	if v, err := strconv.ParseUint(s, 16, 32); err == nil {
+		return Throttled(v), "sysfs"
+	}
+	dirs, _ := filepath.Glob(filepath.Join(root, "sys/class/hwmon/hwmon*"))
+	for _, dir := range dirs {
+		if readString(filepath.Join(dir, "name")) != "rpi_volt" {
+			continue
+		}
+		switch readString(filepath.Join(dir, "in0_lcrit_alarm")) {
+		case "0":
+			return 0, "hwmon"
+		case "1":
+			return THROTTLED_UNDER_VOLTAGE, "hwmon"
+		}
+	}
+	return 0, ""
+}
+
+const mailboxTagGetThrottled = 0x00030046
+
+// mailboxThrottled sends a single GET_THROTTLED property tag.
+func mailboxThrottled(dev string) (v uint32, err error) {
+	file, err := os.OpenFile(dev, os.O_RDWR, 0)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	tag := &property.Tag{ID: mailboxTagGetThrottled, Size: 4}
+	buf, err := property.Encode(tag)
+	if err != nil {
+		return
+	}
+	if err = IoctlBuffer(file.Fd(), IOWROf[uintptr](100, 0), buf); err != nil {
+		return
+	}
+	if err = property.Decode(buf, tag); err != nil {
+		return
+	}
+	if len(tag.Response) < 4 {
+		err = property.ErrMalformed
+		return
+	}
+	v = binary.LittleEndian.Uint32(tag.Response)
+	return
+}
+
+func readString(path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// suffixNumber returns the number ending the base name of path, e.g. 12 for cpu12.
+func suffixNumber(path string) int {
+	base := filepath.Base(path)
+	i := len(base)
+	for i > 0 && base[i-1] >= '0' && base[i-1] <= '9' {
+		i--
+	}
+	n, _ := strconv.Atoi(base[i:])
+	return n
+}
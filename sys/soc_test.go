@@ -0,0 +1,68 @@
+package sys
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	for path, content := range files {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadSoCInfo(t *testing.T) {
+	root := t.TempDir()
+	if _, err := ReadSoCInfo(root); err != os.ErrNotExist {
+		t.Errorf("empty root accepted: %v", err)
+	}
+	writeTree(t, root, map[string]string{
+		"sys/class/thermal/thermal_zone0/type":                 "cpu-thermal\n",
+		"sys/class/thermal/thermal_zone0/temp":                 "48686\n",
+		"sys/class/thermal/thermal_zone10/type":                "other\n",
+		"sys/class/thermal/thermal_zone10/temp":                "-1500\n",
+		"sys/devices/system/cpu/cpu0/cpufreq/scaling_cur_freq": "600000\n",
+		"sys/devices/system/cpu/cpu0/cpufreq/scaling_min_freq": "600000\n",
+		"sys/devices/system/cpu/cpu0/cpufreq/scaling_max_freq": "1500000\n",
+		"sys/devices/system/cpu/cpu0/cpufreq/scaling_governor": "ondemand\n",
+		"sys/devices/system/cpu/cpu1/cpufreq/scaling_cur_freq": "1500000\n",
+		"sys/devices/system/cpu/cpu1/cpufreq/scaling_governor": "performance\n",
+		"sys/devices/system/cpu/cpu2/online":                   "0\n",
+		"sys/class/hwmon/hwmon0/name":                          "rpi_volt\n",
+		"sys/class/hwmon/hwmon0/in0_lcrit_alarm":               "1\n",
+		"sys/devices/platform/soc/soc:firmware/get_throttled":  "50005\n",
+		"dev/vcio": "",
+	})
+	info, err := ReadSoCInfo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if temp, ok := info.Temperature(); !ok || temp != 48.686 || len(info.Zones) != 2 ||
+		info.Zones[1] != (ThermalZone{"thermal_zone10", "other", -1.5}) {
+		t.Errorf("thermal mismatch: %+v", info.Zones)
+	}
+	if len(info.Freqs) != 2 || info.Freqs[0] != (CPUFreq{0, 600000, 600000, 1500000, "ondemand"}) ||
+		info.Freqs[1].CPU != 1 || info.Freqs[1].Cur != 1500000 || info.Freqs[1].Governor != "performance" {
+		t.Errorf("cpufreq mismatch: %+v", info.Freqs)
+	}
+	want := THROTTLED_UNDER_VOLTAGE | THROTTLED_THROTTLED | THROTTLED_UNDER_VOLTAGE_OCCUR | THROTTLED_THROTTLED_OCCUR
+	if info.ThrottledSource != "sysfs" || info.Throttled != want {
+		t.Errorf("throttled mismatch: %s %v", info.ThrottledSource, info.Throttled)
+	}
+	if s := info.Throttled.String(); s != "under-voltage,throttled,under-voltage-occurred,throttled-occurred" {
+		t.Errorf("throttled string mismatch: %s", s)
+	}
+
+	os.Remove(filepath.Join(root, "sys/devices/platform/soc/soc:firmware/get_throttled"))
+	if info, _ = ReadSoCInfo(root); info.ThrottledSource != "hwmon" || info.Throttled != THROTTLED_UNDER_VOLTAGE {
+		t.Errorf("hwmon fallback mismatch: %s %v", info.ThrottledSource, info.Throttled)
+	}
+}
@@ -1,17 +1,23 @@
-//+build linux
+//go:build linux
+// +build linux
 
 package sys
 
 // ref: https://github.com/capnm/sysinfo/blob/master/sysinfo.go
 
 import (
+	"bufio"
 	"fmt"
-	"sync"
+	"io"
+	"os"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
 
-type sysinfo struct {
+// SysInfo is a snapshot of the system state.
+type SysInfo struct {
 	Uptime       time.Duration // time since boot
 	Loads        [3]float64    // 1, 5, and 15 minute load averages, see e.g. UPTIME(1)
 	Procs        uint64        // number of current processes
@@ -23,50 +29,173 @@ type sysinfo struct {
 	FreeSwap     uint64        // swap space still available [kB]
 	TotalHighRam uint64        // total high memory size [kB]
 	FreeHighRam  uint64        // available high memory size [kB]
+	MemAvailable uint64        // estimate of memory available without swapping [kB]
+	CPU          CPUStat       // time spent by all CPUs
+	CPUs         []CPUStat     // time spent by each CPU
+	Root         Filesystem    // usage of the root filesystem [bytes]
+	Filesystems  []Filesystem  // usage of the device-backed filesystems, such as SD cards and USB storage [bytes]
+}
+
+// CPUStat is the time a CPU spent in each state since boot, in USER_HZ ticks.
+type CPUStat struct {
+	Name    string // cpu for all CPUs, cpuN for CPU N
+	User    uint64
+	Nice    uint64
+	System  uint64
+	Idle    uint64
+	IOWait  uint64
+	IRQ     uint64
+	SoftIRQ uint64
+	Steal   uint64
 }
 
 const scale = 65536.0 // magic
 
-var (
-	singelton = &sysinfo{}
-	lock      sync.Mutex
-)
+// Info returns a new snapshot of the system state.
+func Info() (info SysInfo, err error) {
+	err = info.Refresh()
+	return
+}
+
+// Refresh updates info in place.
+func (this *SysInfo) Refresh() error {
+	raw := &syscall.Sysinfo_t{}
+	if err := syscall.Sysinfo(raw); err != nil {
+		return err
+	}
+	unit := uint64(raw.Unit)
+	this.Uptime = time.Duration(raw.Uptime) * time.Second
+	this.Loads[0] = float64(raw.Loads[0]) / scale
+	this.Loads[1] = float64(raw.Loads[1]) / scale
+	this.Loads[2] = float64(raw.Loads[2]) / scale
+	this.Procs = uint64(raw.Procs)
+
+	this.TotalRam = uint64(raw.Totalram) * unit / 1024
+	this.FreeRam = uint64(raw.Freeram) * unit / 1024
+	this.SharedRam = uint64(raw.Sharedram) * unit / 1024
+	this.BufferRam = uint64(raw.Bufferram) * unit / 1024
+	this.TotalSwap = uint64(raw.Totalswap) * unit / 1024
+	this.FreeSwap = uint64(raw.Freeswap) * unit / 1024
+	this.TotalHighRam = uint64(raw.Totalhigh) * unit / 1024
+	this.FreeHighRam = uint64(raw.Freehigh) * unit / 1024
+
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return err
+	}
+	err = decodeMemInfo(file, this)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if file, err = os.Open("/proc/stat"); err != nil {
+		return err
+	}
+	err = decodeStat(file, this)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if this.Root, err = StatFilesystem("/"); err != nil {
+		return err
+	}
+	this.Filesystems, err = GetFilesystems()
+	return err
+}
 
-func Info() (*sysinfo, error) {
-	rawsysinfo := &syscall.Sysinfo_t{}
-	if err := syscall.Sysinfo(rawsysinfo); err != nil {
-		return nil, err
+func decodeMemInfo(r io.Reader, info *SysInfo) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		info.MemAvailable = v
+		return nil
 	}
-	defer lock.Unlock()
-	lock.Lock()
-	unit := uint64(rawsysinfo.Unit) * 1024 // kB
-	singelton.Uptime = time.Duration(rawsysinfo.Uptime) * time.Second
-	singelton.Loads[0] = float64(rawsysinfo.Loads[0]) / scale
-	singelton.Loads[1] = float64(rawsysinfo.Loads[1]) / scale
-	singelton.Loads[2] = float64(rawsysinfo.Loads[2]) / scale
-	singelton.Procs = uint64(rawsysinfo.Procs)
-
-	singelton.TotalRam = uint64(rawsysinfo.Totalram) / unit
-	singelton.FreeRam = uint64(rawsysinfo.Freeram) / unit
-	singelton.BufferRam = uint64(rawsysinfo.Bufferram) / unit
-	singelton.TotalSwap = uint64(rawsysinfo.Totalswap) / unit
-	singelton.FreeSwap = uint64(rawsysinfo.Freeswap) / unit
-	singelton.TotalHighRam = uint64(rawsysinfo.Totalhigh) / unit
-	singelton.FreeHighRam = uint64(rawsysinfo.Freehigh) / unit
-	return singelton, nil
+	// kernels before 3.14 lack MemAvailable
+	info.MemAvailable = info.FreeRam + info.BufferRam
+	return scanner.Err()
 }
 
-func (this sysinfo) String() string {
-	lock.Lock()
-	r := fmt.Sprintf("uptime\t\t%v\nload\t\t%2.2f %2.2f %2.2f\nprocs\t\t%d\n"+
-		"ram  total\t%d kB\nram  free\t%d kB\nram  buffer\t%d kB\n"+
-		"swap total\t%d kB\nswap free\t%d kB",
+func decodeStat(r io.Reader, info *SysInfo) error {
+	info.CPUs = nil // snapshots may share the old one
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		stat := CPUStat{Name: fields[0]}
+		for i, p := range []*uint64{&stat.User, &stat.Nice, &stat.System, &stat.Idle,
+			&stat.IOWait, &stat.IRQ, &stat.SoftIRQ, &stat.Steal} {
+			if i+1 >= len(fields) {
+				break
+			}
+			v, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				return err
+			}
+			*p = v
+		}
+		if stat.Name == "cpu" {
+			info.CPU = stat
+		} else {
+			info.CPUs = append(info.CPUs, stat)
+		}
+	}
+	return scanner.Err()
+}
+
+// Total returns the ticks spent in all states.
+func (this CPUStat) Total() uint64 {
+	return this.User + this.Nice + this.System + this.Idle + this.IOWait + this.IRQ + this.SoftIRQ + this.Steal
+}
+
+// Busy returns the ticks spent neither idle nor waiting for I/O.
+func (this CPUStat) Busy() uint64 {
+	return this.Total() - this.Idle - this.IOWait
+}
+
+// Utilization returns the busy fraction of the CPU between prev and this.
+func (this CPUStat) Utilization(prev CPUStat) float64 {
+	if this.Total() <= prev.Total() || this.Busy() < prev.Busy() {
+		return 0
+	}
+	return float64(this.Busy()-prev.Busy()) / float64(this.Total()-prev.Total())
+}
+
+// Utilization returns the busy fraction of all CPUs and of each CPU between
+// the snapshots prev and this.
+func (this *SysInfo) Utilization(prev *SysInfo) (all float64, cpus []float64) {
+	all = this.CPU.Utilization(prev.CPU)
+	cpus = make([]float64, len(this.CPUs))
+	for i := range cpus {
+		if i < len(prev.CPUs) {
+			cpus[i] = this.CPUs[i].Utilization(prev.CPUs[i])
+		}
+	}
+	return
+}
+
+func (this SysInfo) String() string {
+	s := fmt.Sprintf("uptime\t\t%v\nload\t\t%2.2f %2.2f %2.2f\nprocs\t\t%d\n"+
+		"ram  total\t%d kB\nram  free\t%d kB\nram  avail\t%d kB\nram  buffer\t%d kB\n"+
+		"swap total\t%d kB\nswap free\t%d kB\n"+
+		"root total\t%d kB\nroot avail\t%d kB",
 		//"high ram total\t%d kB\nhigh ram free\t%d kB\n"
 		this.Uptime, this.Loads[0], this.Loads[1], this.Loads[2], this.Procs,
-		this.TotalRam, this.FreeRam, this.BufferRam,
+		this.TotalRam, this.FreeRam, this.MemAvailable, this.BufferRam,
 		this.TotalSwap, this.FreeSwap,
+		this.Root.Size/1024, this.Root.Avail/1024,
 		// archaic this.TotalHighRam, this.FreeHighRam
 	)
-	lock.Unlock()
-	return r
+	for _, fs := range this.Filesystems {
+		s += fmt.Sprintf("\n%s\t%d/%d kB", fs.Path, fs.Used()/1024, fs.Size/1024)
+	}
+	return s
 }
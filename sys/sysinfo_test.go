@@ -0,0 +1,67 @@
+//go:build linux
+// +build linux
+
+package sys
+
+import (
+	"strings"
+	"testing"
+)
+
+const memInfoText = `MemTotal:         944140 kB
+MemFree:          512344 kB
+MemAvailable:     751232 kB
+Buffers:           40372 kB
+`
+
+const statText = `cpu  1000 0 500 8000 100 0 20 0 0 0
+cpu0 500 0 250 4000 50 0 10 0 0 0
+cpu1 500 0 250 4000 50 0 10 0 0 0
+intr 12345 0 0
+ctxt 67890
+`
+
+func TestDecodeMemInfo(t *testing.T) {
+	info := SysInfo{}
+	if err := decodeMemInfo(strings.NewReader(memInfoText), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.MemAvailable != 751232 {
+		t.Errorf("MemAvailable mismatch: %d", info.MemAvailable)
+	}
+}
+
+func TestUtilization(t *testing.T) {
+	prev, cur := SysInfo{}, SysInfo{}
+	if err := decodeStat(strings.NewReader(statText), &prev); err != nil {
+		t.Fatal(err)
+	}
+	if len(prev.CPUs) != 2 || prev.CPU.User != 1000 || prev.CPUs[1].SoftIRQ != 10 {
+		t.Fatalf("stat mismatch: %+v", prev)
+	}
+	cur.CPU, cur.CPUs = prev.CPU, append([]CPUStat(nil), prev.CPUs...)
+	cur.CPU.User += 150
+	cur.CPU.Idle += 250
+	cur.CPU.IOWait += 100
+	cur.CPUs[0].System += 100
+	cur.CPUs[0].Idle += 100
+	cur.CPUs[1].User += 50
+	cur.CPUs[1].Idle += 150
+	all, cpus := cur.Utilization(&prev)
+	if all != 0.3 || len(cpus) != 2 || cpus[0] != 0.5 || cpus[1] != 0.25 {
+		t.Errorf("utilization mismatch: %v %v", all, cpus)
+	}
+	if all, _ = prev.Utilization(&cur); all != 0 {
+		t.Errorf("utilization of reversed snapshots: %v", all)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	info, err := Info()
+	if err != nil {
+		t.Skip(err)
+	}
+	if info.TotalRam == 0 || info.MemAvailable == 0 || len(info.CPUs) == 0 || info.Root.Size == 0 {
+		t.Errorf("info incomplete: %+v", info)
+	}
+}
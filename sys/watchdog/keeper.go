@@ -0,0 +1,102 @@
+package watchdog
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrInterval = errors.New("watchdog: invalid keepalive interval")
+
+// Device is a watchdog that can be kept alive, such as *Watchdog.
+type Device interface {
+	Keepalive() error
+}
+
+// Keeper keeps a watchdog alive as long as the application is healthy. Once
+// the health check fails, or hangs, the keepalives stop and the watchdog
+// resets the system.
+type Keeper struct {
+	dev      Device
+	interval time.Duration
+	check    func() error
+	m        sync.Mutex // serializes keepalives with Stop
+	err      error
+	stopped  bool
+	once     sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewKeeper runs check every interval, a fraction of the timeout, and keeps
+// dev alive after each success. A nil check always succeeds.
+func NewKeeper(dev Device, interval time.Duration, check func() error) (*Keeper, error) {
+	if interval <= 0 {
+		return nil, ErrInterval
+	}
+	k := &Keeper{
+		dev:      dev,
+		interval: interval,
+		check:    check,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go k.run()
+	return k, nil
+}
+
+func (this *Keeper) run() {
+	defer close(this.done)
+	ticker := time.NewTicker(this.interval)
+	defer ticker.Stop()
+	for {
+		err := this.keepalive()
+		if err != nil {
+			this.m.Lock()
+			this.err = err
+			this.m.Unlock()
+			return
+		}
+		select {
+		case <-this.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (this *Keeper) keepalive() error {
+	if this.check != nil {
+		if err := this.check(); err != nil {
+			return err
+		}
+	}
+	defer this.m.Unlock()
+	this.m.Lock()
+	if this.stopped {
+		return nil
+	}
+	return this.dev.Keepalive()
+}
+
+// Err returns the failure which stopped the keepalives, nil while running.
+func (this *Keeper) Err() error {
+	this.m.Lock()
+	defer this.m.Unlock()
+	return this.err
+}
+
+// Done is closed once the keepalives stop.
+func (this *Keeper) Done() <-chan struct{} {
+	return this.done
+}
+
+// Stop stops the keepalives without disabling the watchdog, e.g. before
+// Disable on a clean shutdown. No keepalive is sent once Stop returns; Stop
+// does not wait for a hung check, Done is closed when it returns.
+func (this *Keeper) Stop() {
+	this.m.Lock()
+	this.stopped = true
+	this.m.Unlock()
+	this.once.Do(func() { close(this.stop) })
+}
@@ -0,0 +1,203 @@
+// Package watchdog drives a hardware watchdog through /dev/watchdog, such as
+// the one of the BCM2835 power manager, which resets the system unless kept
+// alive.
+package watchdog
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zyxar/berry/sys"
+)
+
+const DEV_WATCHDOG = "/dev/watchdog"
+
+const (
+	WDIOF_OVERHEAT      = 0x0001 // reset due to CPU overheat
+	WDIOF_FANFAULT      = 0x0002 // fan failed
+	WDIOF_EXTERN1       = 0x0004 // external relay 1
+	WDIOF_EXTERN2       = 0x0008 // external relay 2
+	WDIOF_POWERUNDER    = 0x0010 // power bad/power fault
+	WDIOF_CARDRESET     = 0x0020 // card previously reset the CPU
+	WDIOF_POWEROVER     = 0x0040 // power over voltage
+	WDIOF_SETTIMEOUT    = 0x0080 // set timeout (in seconds)
+	WDIOF_MAGICCLOSE    = 0x0100 // supports magic close char
+	WDIOF_PRETIMEOUT    = 0x0200 // pretimeout (in seconds), get/set
+	WDIOF_ALARMONLY     = 0x0400 // watchdog triggers a management or other external alarm not a reboot
+	WDIOF_KEEPALIVEPING = 0x8000 // keep alive ping reply
+
+	WDIOS_DISABLECARD = 0x0001 // turn off the watchdog timer
+	WDIOS_ENABLECARD  = 0x0002 // turn on the watchdog timer
+) // from <linux/watchdog.h>
+
+const (
+	watchdogIoctlBase = 'W'
+	magicClose        = 'V'
+)
+
+// info is struct watchdog_info.
+type info struct {
+	Options  uint32
+	Firmware uint32
+	Identity [32]byte
+}
+
+var (
+	WDIOC_GETSUPPORT    = sys.IOROf[info](watchdogIoctlBase, 0)
+	WDIOC_GETSTATUS     = sys.IOROf[int32](watchdogIoctlBase, 1)
+	WDIOC_GETBOOTSTATUS = sys.IOROf[int32](watchdogIoctlBase, 2)
+	WDIOC_GETTEMP       = sys.IOROf[int32](watchdogIoctlBase, 3)
+	WDIOC_SETOPTIONS    = sys.IOROf[int32](watchdogIoctlBase, 4) // _IOR, though the driver reads it
+	WDIOC_KEEPALIVE     = sys.IOROf[int32](watchdogIoctlBase, 5)
+	WDIOC_SETTIMEOUT    = sys.IOWROf[int32](watchdogIoctlBase, 6)
+	WDIOC_GETTIMEOUT    = sys.IOROf[int32](watchdogIoctlBase, 7)
+	WDIOC_SETPRETIMEOUT = sys.IOWROf[int32](watchdogIoctlBase, 8)
+	WDIOC_GETPRETIMEOUT = sys.IOROf[int32](watchdogIoctlBase, 9)
+	WDIOC_GETTIMELEFT   = sys.IOROf[int32](watchdogIoctlBase, 10)
+)
+
+var ErrClosed = errors.New("watchdog: closed")
+
+// Info identifies a watchdog and the WDIOF_* flags it supports.
+type Info struct {
+	Options  uint32
+	Firmware uint32
+	Identity string
+}
+
+// Watchdog is an open watchdog. The timer starts on open; unless disabled
+// with Disable, it keeps running after Close and the system resets once the
+// timeout expires.
+type Watchdog struct {
+	m     sync.Mutex
+	file  *os.File // guarded by m, nil once closed
+	ioctl func(request uintptr, v *int32) error
+}
+
+// Open opens DEV_WATCHDOG.
+func Open() (*Watchdog, error) {
+	return OpenDevice(DEV_WATCHDOG)
+}
+
+// OpenDevice opens the watchdog at path, e.g. /dev/watchdog1.
+func OpenDevice(path string) (*Watchdog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watchdog{file: file}
+	w.ioctl = func(request uintptr, v *int32) error {
+		return sys.IoctlPtr(file.Fd(), request, v)
+	}
+	return w, nil
+}
+
+// Info returns the identity and capabilities of the watchdog.
+func (this *Watchdog) Info() (i Info, err error) {
+	defer this.m.Unlock()
+	this.m.Lock()
+	if this.file == nil {
+		err = ErrClosed
+		return
+	}
+	v, err := sys.IoctlRead[info](this.file.Fd(), WDIOC_GETSUPPORT)
+	if err != nil {
+		return
+	}
+	i = Info{v.Options, v.Firmware, strings.TrimRight(string(v.Identity[:]), "\x00")}
+	return
+}
+
+func (this *Watchdog) do(request uintptr, v int32) (int32, error) {
+	defer this.m.Unlock()
+	this.m.Lock()
+	if this.file == nil {
+		return 0, ErrClosed
+	}
+	err := this.ioctl(request, &v)
+	return v, err
+}
+
+// Keepalive restarts the timer.
+func (this *Watchdog) Keepalive() error {
+	_, err := this.do(WDIOC_KEEPALIVE, 0)
+	return err
+}
+
+// Timeout returns the time the watchdog waits for a keepalive.
+func (this *Watchdog) Timeout() (time.Duration, error) {
+	v, err := this.do(WDIOC_GETTIMEOUT, 0)
+	return time.Duration(v) * time.Second, err
+}
+
+// SetTimeout sets the timeout, rounded up to seconds, and returns the one
+// the driver chose, e.g. at most 15s on the BCM2835. The timer restarts.
+func (this *Watchdog) SetTimeout(d time.Duration) (time.Duration, error) {
+	v, err := this.do(WDIOC_SETTIMEOUT, int32((d+time.Second-1)/time.Second))
+	return time.Duration(v) * time.Second, err
+}
+
+// TimeLeft returns the time until reset, if the driver reports it.
+func (this *Watchdog) TimeLeft() (time.Duration, error) {
+	v, err := this.do(WDIOC_GETTIMELEFT, 0)
+	return time.Duration(v) * time.Second, err
+}
+
+// Status returns the WDIOF_* flags of the current state.
+func (this *Watchdog) Status() (uint32, error) {
+	v, err := this.do(WDIOC_GETSTATUS, 0)
+	return uint32(v), err
+}
+
+// BootStatus returns the WDIOF_* flags describing the last reboot.
+func (this *Watchdog) BootStatus() (uint32, error) {
+	v, err := this.do(WDIOC_GETBOOTSTATUS, 0)
+	return uint32(v), err
+}
+
+// WasReset tells whether the watchdog caused the last reboot.
+func (this *Watchdog) WasReset() (bool, error) {
+	v, err := this.BootStatus()
+	return v&WDIOF_CARDRESET != 0, err
+}
+
+// SetOptions sends WDIOS_* options, e.g. to stop the timer of a watchdog
+// without magic close.
+func (this *Watchdog) SetOptions(options uint32) error {
+	_, err := this.do(WDIOC_SETOPTIONS, int32(options))
+	return err
+}
+
+// Disable stops the timer by writing the magic close character before
+// closing. Drivers without WDIOF_MAGICCLOSE, or built with nowayout, keep
+// running and the system resets.
+func (this *Watchdog) Disable() error {
+	defer this.m.Unlock()
+	this.m.Lock()
+	if this.file == nil {
+		return ErrClosed
+	}
+	if _, err := this.file.Write([]byte{magicClose}); err != nil {
+		return err
+	}
+	return this.close()
+}
+
+// Close closes the device, leaving the timer running.
+func (this *Watchdog) Close() error {
+	defer this.m.Unlock()
+	this.m.Lock()
+	return this.close()
+}
+
+func (this *Watchdog) close() error {
+	if this.file == nil {
+		return nil
+	}
+	err := this.file.Close()
+	this.file = nil
+	return err
+}
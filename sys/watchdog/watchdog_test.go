@@ -0,0 +1,232 @@
+package watchdog
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zyxar/berry/sys"
+)
+
+func TestIoctlNumbers(t *testing.T) {
+	if sys.IOC_DIRBITS != 2 {
+		t.Skip("numbers below are those of asm-generic")
+	}
+	for _, c := range []struct {
+		name    string
+		request uintptr
+		want    uintptr
+	}{
+		{"WDIOC_GETSUPPORT", WDIOC_GETSUPPORT, 0x80285700},
+		{"WDIOC_GETBOOTSTATUS", WDIOC_GETBOOTSTATUS, 0x80045702},
+		{"WDIOC_KEEPALIVE", WDIOC_KEEPALIVE, 0x80045705},
+		{"WDIOC_SETTIMEOUT", WDIOC_SETTIMEOUT, 0xC0045706},
+		{"WDIOC_GETTIMEOUT", WDIOC_GETTIMEOUT, 0x80045707},
+		{"WDIOC_GETTIMELEFT", WDIOC_GETTIMELEFT, 0x8004570A},
+	} {
+		if c.request != c.want {
+			t.Errorf("%s mismatch: 0x%08X, expected 0x%08X", c.name, c.request, c.want)
+		}
+	}
+}
+
+// fake emulates the driver of a watchdog with a 15s maximum timeout.
+type fake struct {
+	timeout int32
+	boot    int32
+	pings   int
+}
+
+func (this *fake) ioctl(request uintptr, v *int32) error {
+	switch request {
+	case WDIOC_KEEPALIVE:
+		this.pings++
+	case WDIOC_SETTIMEOUT:
+		if *v > 15 {
+			*v = 15
+		}
+		this.timeout = *v
+	case WDIOC_GETTIMEOUT:
+		*v = this.timeout
+	case WDIOC_GETBOOTSTATUS:
+		*v = this.boot
+	default:
+		return errors.New("unsupported")
+	}
+	return nil
+}
+
+func open(t *testing.T) (*Watchdog, string) {
+	path := filepath.Join(t.TempDir(), "watchdog")
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := OpenDevice(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, path
+}
+
+func TestWatchdog(t *testing.T) {
+	w, path := open(t)
+	f := &fake{timeout: 10, boot: WDIOF_CARDRESET}
+	w.ioctl = f.ioctl
+	if d, err := w.Timeout(); err != nil || d != 10*time.Second {
+		t.Errorf("timeout mismatch: %v %v", d, err)
+	}
+	if d, err := w.SetTimeout(1500 * time.Millisecond); err != nil || d != 2*time.Second {
+		t.Errorf("timeout mismatch: %v %v", d, err)
+	}
+	if d, err := w.SetTimeout(time.Minute); err != nil || d != 15*time.Second {
+		t.Errorf("timeout mismatch: %v %v", d, err)
+	}
+	if reset, err := w.WasReset(); err != nil || !reset {
+		t.Errorf("boot status mismatch: %v %v", reset, err)
+	}
+	if err := w.Keepalive(); err != nil || f.pings != 1 {
+		t.Errorf("keepalive mismatch: %d %v", f.pings, err)
+	}
+	if _, err := w.TimeLeft(); err == nil {
+		t.Error("error expected")
+	}
+	if err := w.Disable(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "V" {
+		t.Errorf("magic close mismatch: %q", b)
+	}
+	if err := w.Keepalive(); err != ErrClosed {
+		t.Errorf("%v expected, got %v", ErrClosed, err)
+	}
+}
+
+type pinger struct {
+	m     sync.Mutex
+	pings int
+}
+
+func (this *pinger) Keepalive() error {
+	this.m.Lock()
+	this.pings++
+	this.m.Unlock()
+	return nil
+}
+
+func (this *pinger) count() int {
+	this.m.Lock()
+	defer this.m.Unlock()
+	return this.pings
+}
+
+func newKeeper(t *testing.T, dev Device, check func() error) *Keeper {
+	k, err := NewKeeper(dev, time.Millisecond, check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKeeper(t *testing.T) {
+	if _, err := NewKeeper(&pinger{}, 0, nil); err != ErrInterval {
+		t.Errorf("%v expected, got %v", ErrInterval, err)
+	}
+
+	p := &pinger{}
+	k := newKeeper(t, p, nil)
+	time.Sleep(20 * time.Millisecond)
+	k.Stop()
+	n := p.count()
+	if n < 2 || k.Err() != nil {
+		t.Errorf("keepalive mismatch: %d %v", n, k.Err())
+	}
+	time.Sleep(5 * time.Millisecond)
+	if p.count() != n {
+		t.Error("keepalive after Stop")
+	}
+	k.Stop()
+
+	p = &pinger{}
+	unhealthy := errors.New("unhealthy")
+	var checks int
+	k = newKeeper(t, p, func() error {
+		if checks++; checks > 3 {
+			return unhealthy
+		}
+		return nil
+	})
+	select {
+	case <-k.Done():
+	case <-time.After(time.Second):
+		t.Fatal("keeper not stopped")
+	}
+	if n := p.count(); n != 3 || k.Err() != unhealthy {
+		t.Errorf("keepalive mismatch: %d %v", n, k.Err())
+	}
+	k.Stop()
+}
+
+func TestKeeperHung(t *testing.T) {
+	p := &pinger{}
+	hung, checked := make(chan struct{}), make(chan struct{}, 1)
+	var checks int
+	k := newKeeper(t, p, func() error {
+		if checks++; checks > 1 {
+			checked <- struct{}{}
+			<-hung
+		}
+		return nil
+	})
+	<-checked
+	stopped := make(chan struct{})
+	go func() {
+		k.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop waits for a hung check")
+	}
+	close(hung)
+	<-k.Done()
+	if n := p.count(); n != 1 || k.Err() != nil {
+		t.Errorf("keepalive mismatch: %d %v", n, k.Err())
+	}
+}
+
+func TestKeeperClose(t *testing.T) {
+	w, _ := open(t)
+	f := &fake{}
+	var m sync.Mutex
+	w.ioctl = func(request uintptr, v *int32) error {
+		m.Lock()
+		defer m.Unlock()
+		return f.ioctl(request, v)
+	}
+	k := newKeeper(t, w, nil)
+	time.Sleep(5 * time.Millisecond)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-k.Done():
+	case <-time.After(time.Second):
+		t.Fatal("keeper not stopped")
+	}
+	if k.Err() != ErrClosed {
+		t.Errorf("%v expected, got %v", ErrClosed, k.Err())
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			k.Stop()
+		}()
+	}
+	wg.Wait()
+}